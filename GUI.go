@@ -1,17 +1,23 @@
 // This application displays the weather for a list of cities.
-// The weather data is fetched from the WeatherAPI and updated every 30 seconds.
+// The weather data is fetched from the WeatherAPI and every city is refreshed in
+// the background, by default every 5 minutes.
 
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
 	"fmt"
 	"image/color"
+	"os"
 	"time"
 
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/app"
 	"fyne.io/fyne/v2/canvas"
 	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
 	"fyne.io/fyne/v2/theme"
 	"fyne.io/fyne/v2/widget"
 )
@@ -20,13 +26,15 @@ import (
 type TodayWeather struct {
 	today       *canvas.Text
 	description *canvas.Text
+	icon        *canvas.Image // condition icon, next to the description
+	highLow     *canvas.Text  // today's high and low, next to the current reading
 }
 
 // This struct stores the graphic elements displaying the weather forecast
 type Forecast struct {
-	forecast1 *canvas.Text
-	forecast2 *canvas.Text
-	forecast3 *canvas.Text
+	title       *widget.Label   // heading naming how many days are forecast
+	days        *fyne.Container // one column per forecast day, as many as the forecast has
+	unavailable *widget.Label   // shown instead of the temperatures when the forecast failed to load
 }
 
 // This struct stores the graphic elements displaying the weather details
@@ -40,33 +48,64 @@ type TodayDetails struct {
 }
 
 func main() {
+	// settings from the environment, the config file and the command line
+	config, err := loadConfig(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		fmt.Println("Error loading configuration:", err)
+		showStartupError(err, config.configPath(configFileName))
+		return
+	}
+
+	// source of the weather data displayed by the application, optionally
+	// recording its responses or replaying recorded ones, with the responses
+	// cached on disk to save quota
+	client := newHTTPClient(config.ConnectTimeout, config.RequestTimeout)
+	if config.RecordDir != "" {
+		client.Transport = &recordingTransport{Dir: config.RecordDir, Base: client.Transport, Secret: config.APIKey}
+	}
+	if config.ReplayDir != "" {
+		client.Transport = replayTransport{Dir: config.ReplayDir}
+	}
+	// client-side limits on the calls that reach the WeatherAPI, cache hits are free
+	var quota *quotaTracker
+	if config.ReplayDir == "" {
+		quota = newQuotaTracker(config.configPath(usageFileName), config.DailyQuota, config.MonthlyQuota, config.QuotaWarning)
+		client.Transport = newRateLimitTransport(client.Transport, config.RateLimit, config.Burst, config.MaxConcurrent, quota)
+	}
+	if config.CacheDir != "" {
+		client.Transport = newCachingTransport(config.CacheDir, client.Transport, config.CacheTTL)
+	}
+	provider := newWeatherAPIProvider(config.APIKey, client)
+	provider.BaseURL = config.BaseURL
+	provider.Retry = config.Retry
+	provider.Days = config.ForecastDays
+
 	//model that holds the current state of the program -- NOEL
 	currentState := CurrentState{
 		CityNames:      []string{},
 		WeatherDataMap: make(map[string]WeatherData),
 	}
-	//adding cities from cityNames.txt
-	if err := loadCityNamesFromFile(&currentState); err != nil {
+	//adding cities from cityNames.txt, there are none on the first run
+	cityNamesPath := config.configPath(cityNamesFileName)
+	//the list used to be kept in the working directory
+	if migrated, err := migrateCityNames(cityNamesFileName, cityNamesPath); err != nil {
+		fmt.Println("Error moving", cityNamesFileName, "to", cityNamesPath+":", err)
+	} else if migrated {
+		fmt.Println("Copied", cityNamesFileName, "to", cityNamesPath+", the copy in this directory is no longer used")
+	}
+	if err := loadCityNamesFromFile(&currentState, cityNamesPath); err != nil {
 		fmt.Println("Error loading city names into file:", err)
+		showStartupError(fmt.Errorf("the city list could not be read: %w", err), config.configPath(configFileName))
 		return
 	}
 
-	dataChannel := make(chan WeatherData, len(currentState.CityNames)) // Create a buffered channel
-
-	// Launch Goroutines to fetch and process data for each city
-	for _, cityName := range currentState.CityNames {
-		go func(cityName string) {
-			cityData := getCityData(cityName)
-			dataChannel <- cityData // Send the data to the channel
-		}(cityName)
-	}
-
-	// Receive the data from the channel for each city
-	for range currentState.CityNames {
-		cityData := <-dataChannel
-		// Add cityData to the WeatherDataMap with cityName as the key
-		currentState.WeatherDataMap[cityData.CityName] = cityData
-	}
+	cachedCities := loadStartupWeather(provider, &currentState, config)
+	// from here on the state is shared with the refresh scheduler, so it is only
+	// accessed through the store
+	store := newWeatherStore(currentState)
 
 	//GUI initailization
 	myApp := app.New()
@@ -80,10 +119,11 @@ func main() {
 	metric := false
 	myApp.Settings().SetTheme(theme.DarkTheme())
 
-	// current view variable
-	currentCity := currentState.CityNames[0]
-	currentCityData := currentState.WeatherDataMap[currentCity]
-	cities := currentState.CityNames
+	// current view variable, only accessed from the Fyne event loop; the pinned
+	// default city at startup, empty when there are no cities
+	cities := store.CityNames()
+	currentCity := store.StartCity()
+	currentCityData, _ := store.Weather(currentCity)
 
 	// today weather display, assigning values
 	var tempToday float64
@@ -100,35 +140,25 @@ func main() {
 	todayTemperatureDescription.Alignment = fyne.TextAlignCenter
 	todayTemperatureDescription.TextStyle.Bold = true
 	todayTemperatureDescription.TextSize = 20
-	todayWeather := TodayWeather{todayTemperatureReading, todayTemperatureDescription}
+	todayIcon := canvas.NewImageFromResource(nil)
+	todayIcon.FillMode = canvas.ImageFillContain
+	todayIcon.SetMinSize(fyne.NewSize(48, 48))
+	todayHighLow := canvas.NewText("", textColor)
+	todayHighLow.TextSize = 20
+	todayWeather := TodayWeather{todayTemperatureReading, todayTemperatureDescription, todayIcon, todayHighLow}
 
-	// weather forecast for the next three days, assigning values
-	var tempDay1 float64
-	var tempDay2 float64
-	var tempDay3 float64
-	if metric {
-		tempDay1 = currentCityData.TempC1
-		tempDay2 = currentCityData.TempC2
-		tempDay3 = currentCityData.TempC3
-	} else {
-		tempDay1 = currentCityData.TempF1
-		tempDay2 = currentCityData.TempF2
-		tempDay3 = currentCityData.TempF3
-	}
-	tempDay1Str := fmt.Sprintf("%.0f", tempDay1)
-	tempDay2Str := fmt.Sprintf("%.0f", tempDay2)
-	tempDay3Str := fmt.Sprintf("%.0f", tempDay3)
-	forecast1 := canvas.NewText(" "+tempDay1Str+"° ", textColor)
-	forecast1.TextSize = 50
-	forecast1.Alignment = fyne.TextAlignCenter
-	forecast2 := canvas.NewText(" "+tempDay2Str+"° ", textColor)
-	forecast2.TextSize = 50
-	forecast2.Alignment = fyne.TextAlignCenter
-	forecast3 := canvas.NewText(" "+tempDay3Str+"° ", textColor)
-	forecast3.TextSize = 50
-	forecast3.Alignment = fyne.TextAlignCenter
-	forecastContainer := container.NewHBox(widget.NewLabel("       "), forecast1, forecast2, forecast3)
-	forecast := Forecast{forecast1, forecast2, forecast3}
+	// hourly forecast for the next hours, scrolled sideways, filled in by updateHourly
+	hourlyStrip := container.NewHBox()
+	updateHourly(hourlyStrip, metric, currentCityData, hourlyStart(currentCityData, config.ReplayDir != "", time.Now()))
+
+	// weather forecast for the coming days, one column per day filled in by updateForecasts
+	forecastTitle := widget.NewLabel("")
+	forecastDays := container.NewHBox()
+	forecastUnavailable := widget.NewLabel("Forecast unavailable")
+	forecastUnavailable.Hide()
+	forecastContainer := container.NewVBox(container.NewHScroll(container.NewHBox(widget.NewLabel("       "), forecastDays)), forecastUnavailable)
+	forecast := Forecast{forecastTitle, forecastDays, forecastUnavailable}
+	updateForecasts(forecast, metric, currentCity, currentCityData, textColor)
 
 	// weather details for today (humidity, wind speed, etc.)
 	var windSpeedVal float64
@@ -164,44 +194,106 @@ func main() {
 	weatherDetailsContainerOuter := container.NewStack(weatherDetailsBg, weatherDetailsContainerInner)
 
 	// last updated time label
-	lastUpdated := widget.NewLabel("Last Updated: " + time.Now().Format("15:04:05"))
+	lastUpdated := widget.NewLabel(lastUpdatedText(currentCityData, time.Now()))
 	lastUpdated.Alignment = fyne.TextAlignCenter
 
+	// warning shown once most of the WeatherAPI quota is used
+	quotaWarning := widget.NewLabel("")
+	quotaWarning.Importance = widget.WarningImportance
+	quotaWarning.Alignment = fyne.TextAlignCenter
+	updateQuotaWarning(quotaWarning, quota)
+
+	// everything shown about the current city
+	weatherView := container.NewVBox(
+		container.NewVBox(container.NewVBox(widget.NewLabel("Current Weather"))),
+		container.NewCenter(container.NewHBox(todayIcon, todayTemperatureDescription)),
+		container.NewCenter(container.NewHBox(todayTemperatureReading, todayHighLow)),
+		container.NewHScroll(hourlyStrip),
+		container.NewVBox(container.NewVBox(forecastTitle)),
+		forecastContainer,
+		container.NewVBox(container.NewVBox(widget.NewLabel("Real Time Weather Details"))),
+		weatherDetailsContainerOuter,
+		lastUpdated,
+	)
+
+	// shown instead while there are no cities, on the first run or after removing them all
+	onboardingTitle := widget.NewLabelWithStyle("Welcome to the Weather App!", fyne.TextAlignCenter, fyne.TextStyle{Bold: true})
+	onboardingText := widget.NewLabel("Type the name of a city in the box above and press Add City to see its weather. " +
+		"The cities you add are remembered for the next time.")
+	onboardingText.Wrapping = fyne.TextWrapWord
+	onboarding := container.NewVBox(onboardingTitle, onboardingText)
+
+	// redraws everything with the data of the current city
+	showCurrentCity := func() {
+		if currentCity == "" {
+			weatherView.Hide()
+			onboarding.Show()
+		} else {
+			onboarding.Hide()
+			weatherView.Show()
+		}
+		updateToday(todayWeather, metric, currentCity, currentCityData)
+		updateHourly(hourlyStrip, metric, currentCityData, hourlyStart(currentCityData, config.ReplayDir != "", time.Now()))
+		updateForecasts(forecast, metric, currentCity, currentCityData, textColor)
+		updateTodayDetails(todayDetails, metric, currentCity, currentCityData)
+		lastUpdated.SetText(lastUpdatedText(currentCityData, time.Now()))
+	}
+
 	// dropdown menu to select cities
 	citySelect := widget.NewSelect(cities, func(s string) {
 		fmt.Println("Selected", s)
 		currentCity = s
-		currentCityData = currentState.WeatherDataMap[currentCity]
-		updateToday(todayWeather, metric, currentCity, currentCityData)
-		updateForecasts(forecast, metric, currentCity, currentCityData)
-		updateTodayDetails(todayDetails, metric, currentCity, currentCityData)
+		currentCityData, _ = store.Weather(currentCity)
+		showCurrentCity()
 	})
-	citySelect.SetSelected(currentState.CityNames[0]) // Set default city
+	citySelect.PlaceHolder = "No cities yet"
+	if currentCity != "" {
+		citySelect.SetSelected(currentCity) // Set default city
+	} else {
+		showCurrentCity()
+	}
 
 	// textbox and button for adding new cities
 	newCityInput := widget.NewEntry()
 	newCityInput.SetPlaceHolder("Enter City Name to Add")
-	addCityButton := widget.NewButton("Add City", func() {
+	addCityButton := widget.NewButton("Add City", nil)
+	addCityButton.OnTapped = func() {
 		// -- NOEL
-		currentCity := newCityInput.Text
-		currentCityData := getCityData(currentCity)
-		if currentCityData.GoodResponse {
-			currentState.CityNames = append(currentState.CityNames, currentCity)
-			currentState.WeatherDataMap[currentCity] = currentCityData
-		} else {
-			//TODO: bring up alert saying invalid city name or API may be down
-			fmt.Print("Invalid city name provided or the weather API may be down.")
+		newCity := newCityInput.Text
+		//no need to fetch a city that is already tracked
+		if store.HasCity(newCity) {
+			dialog.ShowInformation("Could not add "+newCity, newCity+" is already in the list.", myWindow)
+			return
 		}
-		cities = currentState.CityNames
-		citySelect.Options = cities
-		citySelect.SetSelected(currentCity)
-		citySelect.Refresh()
-		//clear the input box
-		newCityInput.SetPlaceHolder("Enter City Name to Add")
-		newCityInput.SetText("")
-		//save to file
-		writeCityNamesToFile(currentState)
-	})
+		addCityButton.Disable()
+		// fetch in the background so the window stays responsive
+		go func() {
+			newCityData, err := getCityData(context.Background(), provider, newCity)
+			fyne.Do(func() {
+				addCityButton.Enable()
+				if err != nil {
+					// keep the input so the name can be corrected
+					dialog.ShowInformation("Could not add "+newCity, errorMessage(err), myWindow)
+					return
+				}
+				//another click may have added it while this one was fetching
+				if !store.AddCity(newCity, newCityData) {
+					dialog.ShowInformation("Could not add "+newCity, newCity+" is already in the list.", myWindow)
+					return
+				}
+				citySelect.Options = store.CityNames()
+				citySelect.SetSelected(newCity)
+				citySelect.Refresh()
+				//clear the input box
+				newCityInput.SetPlaceHolder("Enter City Name to Add")
+				newCityInput.SetText("")
+				//save to file
+				if err := writeCityNamesToFile(store.Snapshot(), cityNamesPath); err != nil {
+					fmt.Println("Error saving city names:", err)
+				}
+			})
+		}()
+	}
 	cityInputContainer := container.NewBorder(nil, nil, nil, addCityButton, newCityInput)
 	cityInputContainer.Resize(fyne.NewSize(400, 50))
 
@@ -212,9 +304,7 @@ func main() {
 		} else {
 			metric = false
 		}
-		updateToday(todayWeather, metric, currentCity, currentCityData)
-		updateForecasts(forecast, metric, currentCity, currentCityData)
-		updateTodayDetails(todayDetails, metric, currentCity, currentCityData)
+		showCurrentCity()
 	})
 
 	// toggle button for dark mode
@@ -224,57 +314,150 @@ func main() {
 			textColor = color.White
 			todayTemperatureReading.Color = textColor
 			todayTemperatureDescription.Color = textColor
-			forecast1.Color = textColor
-			forecast2.Color = textColor
-			forecast3.Color = textColor
+			todayHighLow.Color = textColor
 			weatherDetailsBg.FillColor = bgColorDark
 		} else {
 			myApp.Settings().SetTheme(theme.LightTheme())
 			textColor = color.Black
 			todayTemperatureReading.Color = textColor
 			todayTemperatureDescription.Color = textColor
-			forecast1.Color = textColor
-			forecast2.Color = textColor
-			forecast3.Color = textColor
+			todayHighLow.Color = textColor
 			weatherDetailsBg.FillColor = bgcolorLight
 		}
+		updateForecasts(forecast, metric, currentCity, currentCityData, textColor)
 	})
 	darkModeToggle.SetChecked(true)
 
+	// scheduler that refreshes every tracked city, spread over the refresh interval;
+	// refreshes skip the cache so that every city is fetched once per interval
+	scheduler := newRefreshScheduler(config.RefreshInterval, store.CityNames, func(ctx context.Context, cityName string) {
+		freshData, err := getCityData(withRevalidate(ctx), provider, cityName)
+		if ctx.Err() != nil {
+			return // shutting down
+		}
+		if err != nil {
+			fmt.Println("Error refreshing weather for", cityName+":", err)
+		}
+		// a failed refresh keeps the previous reading, marked as stale
+		store.UpdateWeather(cityName, func(previous WeatherData) WeatherData {
+			return keepLastGood(previous, freshData, err)
+		})
+	})
+
+	// redraw when the store changes, which the refresh scheduler does from its own
+	// goroutine, so the widgets are only touched from the Fyne event loop
+	unsubscribe := store.Subscribe(func(event StoreEvent) {
+		fyne.Do(func() {
+			updateQuotaWarning(quotaWarning, quota)
+			if event.CitiesChanged {
+				citySelect.Options = store.CityNames()
+				citySelect.Refresh()
+			}
+			if event.CityName == currentCity {
+				currentCityData, _ = store.Weather(currentCity)
+				showCurrentCity()
+			}
+		})
+	})
+	defer unsubscribe()
+
+	// button to refresh the selected city right away
+	refreshButton := widget.NewButtonWithIcon("", theme.ViewRefreshIcon(), func() {
+		if currentCity != "" {
+			scheduler.RefreshNow(currentCity)
+		}
+	})
+
+	// button to stop tracking the selected city, after asking
+	removeCityButton := widget.NewButtonWithIcon("", theme.DeleteIcon(), func() {
+		cityName := currentCity
+		if cityName == "" {
+			return
+		}
+		dialog.ShowConfirm("Remove "+cityName, "Stop showing the weather for "+cityName+"?", func(remove bool) {
+			if !remove || !store.RemoveCity(cityName) {
+				return
+			}
+			if err := writeCityNamesToFile(store.Snapshot(), cityNamesPath); err != nil {
+				fmt.Println("Error saving city names:", err)
+			}
+			// show the default city, or nothing once the list is empty
+			citySelect.Options = store.CityNames()
+			if startCity := store.StartCity(); startCity != "" {
+				citySelect.SetSelected(startCity)
+			} else {
+				citySelect.ClearSelected()
+			}
+		}, myWindow)
+	})
+
+	// button to reorder the cities and pin the default one
+	manageCitiesButton := widget.NewButtonWithIcon("", theme.ListIcon(), func() {
+		showCityManager(store, cityNamesPath, myWindow)
+	})
+
 	// container for the dropdown menu and toggle buttons
-	citySelectContainerHorizontal := container.NewHBox(citySelect, tempUnitsToggle, darkModeToggle)
+	citySelectContainerHorizontal := container.NewHBox(citySelect, refreshButton, removeCityButton, manageCitiesButton, tempUnitsToggle, darkModeToggle)
 	citySelectContainer := container.NewVBox(citySelectContainerHorizontal)
 
 	// Assemble the GUI
 	mainGUI := citySelectContainer
 	mainGUI.Add(cityInputContainer)
-	mainGUI.Add(container.NewVBox(container.NewVBox(widget.NewLabel("Today's Average"))))
-	mainGUI.Add(todayTemperatureDescription)
-	mainGUI.Add(todayTemperatureReading)
-	mainGUI.Add(container.NewVBox(container.NewVBox(widget.NewLabel("Next 3 Days' Average"))))
-	mainGUI.Add(forecastContainer)
-	mainGUI.Add(container.NewVBox(container.NewVBox(widget.NewLabel("Real Time Weather Details"))))
-	mainGUI.Add(weatherDetailsContainerOuter)
-	mainGUI.Add(lastUpdated)
+	mainGUI.Add(onboarding)
+	mainGUI.Add(weatherView)
+	mainGUI.Add(quotaWarning)
 	myWindow.SetContent(mainGUI)
+	if currentCity == "" {
+		myWindow.Canvas().Focus(newCityInput)
+	}
 
-	// This goroutine updates the weather data every 30 seconds
-	go func() {
-		for {
-			time.Sleep(30 * time.Second)
-			//--NOEL
-			currentCityData := getCityData(currentCity)
-			currentState.WeatherDataMap[currentCity] = currentCityData
-			updateToday(todayWeather, metric, currentCity, currentCityData)
-			updateForecasts(forecast, metric, currentCity, currentCityData)
-			updateTodayDetails(todayDetails, metric, currentCity, currentCityData)
-			lastUpdated.SetText("Last Updated: " + time.Now().Format("15:04:05"))
-		}
-	}()
+	// keep every city current in the background
+	refreshCtx, stopRefresh := context.WithCancel(context.Background())
+	defer stopRefresh()
+	go scheduler.Run(refreshCtx)
+	scheduler.RefreshNow(cachedCities...)
 
 	myWindow.ShowAndRun()
 }
 
+// loadStartupWeather fills state with the weather of its cities for the window to
+// open with, and returns the cities whose data came from the cache, which are
+// still to be fetched again. The cached responses are used however old, marked
+// stale when they are older than config.CacheTTL (see markOld); with the cache
+// turned off every city is fetched. The fetches give up when
+// config.StartupTimeout passes.
+func loadStartupWeather(provider WeatherProvider, state *CurrentState, config Config) (cachedCities []string) {
+	uncachedCities := state.CityNames
+	if config.CacheDir != "" {
+		cachedDataMap, _ := getAllCityData(withCacheOnly(context.Background()), provider, state.CityNames)
+		uncachedCities = []string{}
+		for _, cityName := range state.CityNames {
+			if cityData, ok := cachedDataMap[cityName]; ok {
+				state.WeatherDataMap[cityName] = markOld(cityData, config.CacheTTL, time.Now())
+				cachedCities = append(cachedCities, cityName)
+			} else {
+				uncachedCities = append(uncachedCities, cityName)
+			}
+		}
+	}
+
+	// fetch the other cities, giving up on slow ones when the startup deadline passes
+	startupCtx, cancelStartup := context.WithTimeout(context.Background(), config.StartupTimeout)
+	weatherDataMap, fetchErrors := getAllCityData(startupCtx, provider, uncachedCities)
+	cancelStartup()
+	for cityName, err := range fetchErrors {
+		fmt.Println("Error fetching weather for", cityName+":", err)
+	}
+	for _, cityName := range uncachedCities {
+		if cityData, ok := weatherDataMap[cityName]; ok {
+			state.WeatherDataMap[cityName] = cityData
+		} else if fetchErrors[cityName] == nil {
+			fmt.Println("Weather for", cityName, "did not load before the startup deadline")
+		}
+	}
+	return cachedCities
+}
+
 // This function updates the today's temperature and description with the current city's data
 func updateToday(todayWeather TodayWeather, metric bool, currentCity string, currentCityData WeatherData) {
 	var tempToday float64
@@ -289,8 +472,110 @@ func updateToday(todayWeather TodayWeather, metric bool, currentCity string, cur
 	tempTodayStr := fmt.Sprintf("%.0f", tempToday)
 	today.Text = " " + tempTodayStr + "°"
 	description.Text = currentCityData.Condition
+	todayWeather.icon.Resource = conditionIcon(currentCityData.ConditionCode, currentCityData.IsDay)
+	//today's high and low come from the first forecast day
+	todayWeather.highLow.Text = ""
+	if len(currentCityData.Forecast) > 0 && currentCityData.ForecastErr == nil {
+		high, low := highLow(currentCityData.Forecast[0], metric)
+		todayWeather.highLow.Text = fmt.Sprintf("H %.0f° / L %.0f°", high, low)
+	}
+	//cities that did not load before the startup deadline have no data yet
+	if currentCityData.CityName == "" {
+		today.Text = " --°"
+		description.Text = "Waiting for data"
+		todayWeather.icon.Resource = nil
+	}
 	today.Refresh()
 	description.Refresh()
+	todayWeather.icon.Refresh()
+	todayWeather.highLow.Refresh()
+}
+
+// showCityManager opens a dialog listing the tracked cities, where they can be
+// moved up or down and one pinned as the default city shown at startup. Every
+// change is saved to the city list at cityNamesPath right away.
+func showCityManager(store *WeatherStore, cityNamesPath string, window fyne.Window) {
+	var list *widget.List
+	// saves and redraws the list after a change to the store
+	changed := func(ok bool) {
+		if !ok {
+			return
+		}
+		if err := writeCityNamesToFile(store.Snapshot(), cityNamesPath); err != nil {
+			fmt.Println("Error saving city names:", err)
+		}
+		list.Refresh()
+	}
+	list = widget.NewList(
+		func() int {
+			return len(store.CityNames())
+		},
+		func() fyne.CanvasObject {
+			buttons := container.NewHBox(
+				widget.NewButtonWithIcon("", theme.MoveUpIcon(), nil),
+				widget.NewButtonWithIcon("", theme.MoveDownIcon(), nil),
+				widget.NewButtonWithIcon("", theme.HomeIcon(), nil),
+			)
+			return container.NewBorder(nil, nil, nil, buttons, widget.NewLabel(""))
+		},
+		func(id widget.ListItemID, item fyne.CanvasObject) {
+			cities := store.CityNames()
+			if id >= len(cities) {
+				return
+			}
+			cityName := cities[id]
+			isDefault := cityName == store.DefaultCity()
+			row := item.(*fyne.Container)
+			label := row.Objects[0].(*widget.Label)
+			buttons := row.Objects[1].(*fyne.Container).Objects
+			up, down, pin := buttons[0].(*widget.Button), buttons[1].(*widget.Button), buttons[2].(*widget.Button)
+
+			text := cityName
+			if isDefault {
+				text += " (default)"
+			}
+			label.TextStyle.Bold = isDefault
+			label.SetText(text)
+			up.OnTapped = func() { changed(store.MoveCity(cityName, -1)) }
+			down.OnTapped = func() { changed(store.MoveCity(cityName, 1)) }
+			// the pin button of the default city unpins it again
+			pin.OnTapped = func() {
+				if isDefault {
+					changed(store.SetDefaultCity(""))
+				} else {
+					changed(store.SetDefaultCity(cityName))
+				}
+			}
+			setEnabled(up, id > 0)
+			setEnabled(down, id < len(cities)-1)
+			if isDefault {
+				pin.Importance = widget.HighImportance
+			} else {
+				pin.Importance = widget.MediumImportance
+			}
+			pin.Refresh()
+		})
+
+	manager := dialog.NewCustom("Cities", "Close", list, window)
+	manager.Resize(fyne.NewSize(360, 400))
+	manager.Show()
+}
+
+// setEnabled enables or disables button
+func setEnabled(button *widget.Button, enabled bool) {
+	if enabled {
+		button.Enable()
+	} else {
+		button.Disable()
+	}
+}
+
+// highLow returns the high and low temperature of day in the chosen units
+func highLow(day DailyForecast, metric bool) (high, low float64) {
+	if metric {
+		return day.MaxTempC, day.MinTempC
+	}
+	return day.MaxTempF, day.MinTempF
 }
 
 //his function updates the today's details with the current city's data
@@ -335,33 +620,179 @@ func updateTodayDetails(todayDetails TodayDetails, metric bool, currentCity stri
 	uv.Refresh()
 }
 
+//This function fills the hourly strip with one column per hour from the current
+//hour on: time, temperature, feels-like, chance of rain, wind and condition
+func updateHourly(strip *fyne.Container, metric bool, currentCityData WeatherData, now time.Time) {
+	hours := currentCityData.nextHours(now, maxForecastHours)
+	cells := make([]fyne.CanvasObject, 0, len(hours))
+	for _, hour := range hours {
+		temp, feelsLike := hour.TempF, hour.FeelsLikeF
+		wind := fmt.Sprintf("%.0fmph", hour.WindMPH)
+		if metric {
+			temp, feelsLike = hour.TempC, hour.FeelsLikeC
+			wind = fmt.Sprintf("%.0fkph", hour.WindKPH)
+		}
+		cells = append(cells, container.NewVBox(
+			widget.NewLabelWithStyle(hour.Time.Format("15:04"), fyne.TextAlignCenter, fyne.TextStyle{Bold: true}),
+			widget.NewLabelWithStyle(fmt.Sprintf("%.0f°", temp), fyne.TextAlignCenter, fyne.TextStyle{}),
+			widget.NewLabelWithStyle(fmt.Sprintf("feels %.0f°", feelsLike), fyne.TextAlignCenter, fyne.TextStyle{}),
+			widget.NewLabelWithStyle(fmt.Sprintf("%.0f%% rain", hour.ChanceOfRain), fyne.TextAlignCenter, fyne.TextStyle{}),
+			widget.NewLabelWithStyle(wind+" "+hour.WindDir, fyne.TextAlignCenter, fyne.TextStyle{}),
+			widget.NewLabelWithStyle(hour.Condition, fyne.TextAlignCenter, fyne.TextStyle{Italic: true}),
+		))
+	}
+	if len(cells) == 0 {
+		cells = append(cells, widget.NewLabel("Hourly forecast unavailable"))
+	}
+	strip.Objects = cells
+	strip.Refresh()
+}
+
+// hourlyStart returns the time the hourly strip starts at: now, or when the data
+// is replayed, the time it was recorded, since its hours are long past
+func hourlyStart(data WeatherData, replaying bool, now time.Time) time.Time {
+	if replaying && !data.UpdatedAt.IsZero() {
+		return data.UpdatedAt
+	}
+	return now
+}
+
 //This function updates the forecast with the current city's data
-func updateForecasts(forecast Forecast, metric bool, currentCity string, currentCityData WeatherData) {
-	forecast1 := forecast.forecast1
-	forecast2 := forecast.forecast2
-	forecast3 := forecast.forecast3
-	var tempDay1 float64
-	var tempDay2 float64
-	var tempDay3 float64
-	if metric {
-		tempDay1 = currentCityData.TempC1
-		tempDay2 = currentCityData.TempC2
-		tempDay3 = currentCityData.TempC3
+func updateForecasts(forecast Forecast, metric bool, currentCity string, currentCityData WeatherData, textColor color.Color) {
+	days := currentCityData.Forecast
+	//the forecast starts with today
+	forecast.title.SetText(fmt.Sprintf("%d-Day Forecast", len(days)))
+	//the current conditions may have loaded without the forecast
+	if currentCityData.ForecastErr != nil || len(days) == 0 {
+		forecast.days.Hide()
+		if currentCityData.ForecastErr != nil {
+			forecast.unavailable.SetText("Forecast unavailable: " + errorMessage(currentCityData.ForecastErr))
+		} else {
+			forecast.unavailable.SetText("Forecast unavailable")
+		}
+		forecast.unavailable.Show()
+		return
+	}
+	forecast.unavailable.Hide()
+
+	//one column per day: its name, the condition icon, the high and, smaller, the low
+	cells := make([]fyne.CanvasObject, 0, len(days))
+	for i, day := range days {
+		high, low := highLow(day, metric)
+		name := canvas.NewText(forecastDayName(day, i), textColor)
+		name.TextStyle.Bold = true
+		name.Alignment = fyne.TextAlignCenter
+		icon := canvas.NewImageFromResource(conditionIcon(day.ConditionCode, true))
+		icon.FillMode = canvas.ImageFillContain
+		icon.SetMinSize(fyne.NewSize(40, 40))
+		highText := canvas.NewText(" "+fmt.Sprintf("%.0f", high)+"° ", textColor)
+		highText.TextSize = 40
+		highText.Alignment = fyne.TextAlignCenter
+		lowText := canvas.NewText(fmt.Sprintf("%.0f", low)+"°", textColor)
+		lowText.TextSize = 24
+		lowText.Alignment = fyne.TextAlignCenter
+		cells = append(cells, container.NewVBox(name, icon, highText, lowText))
+	}
+	forecast.days.Objects = cells
+	forecast.days.Show()
+	forecast.days.Refresh()
+}
+
+// forecastDayName returns the name shown above the i-th forecast day, "Today"
+// for the first one and the day of the week, e.g. "Mon", for the others
+func forecastDayName(day DailyForecast, i int) string {
+	if i == 0 {
+		return "Today"
+	}
+	return day.Date.Format("Mon")
+}
+
+// lastUpdatedText builds the "Last Updated" label from the time the data was updated
+// by the WeatherAPI, with the date unless that was today, and says how old the
+// data is when it is stale
+func lastUpdatedText(data WeatherData, now time.Time) string {
+	if data.UpdatedAt.IsZero() {
+		if data.RefreshErr != nil {
+			return "Update failed: " + errorMessage(data.RefreshErr)
+		}
+		return "Last Updated: never"
+	}
+	updatedAt := data.UpdatedAt.Local()
+	layout := "15:04:05"
+	if updatedAt.Format("2006-01-02") != now.Local().Format("2006-01-02") {
+		layout = "Jan 2 15:04:05"
+	}
+	text := "Last Updated: " + updatedAt.Format(layout)
+	if data.Stale {
+		text += " (stale, " + formatAge(now.Sub(data.UpdatedAt)) + " old)"
+	}
+	return text
+}
+
+// updateQuotaWarning shows the quota warning of quota in label, and hides label
+// while there is none
+func updateQuotaWarning(label *widget.Label, quota *quotaTracker) {
+	warning := ""
+	if quota != nil {
+		warning = quota.Warning()
+	}
+	label.SetText(warning)
+	if warning == "" {
+		label.Hide()
 	} else {
-		tempDay1 = currentCityData.TempF1
-		tempDay2 = currentCityData.TempF2
-		tempDay3 = currentCityData.TempF3
+		label.Show()
 	}
-	//building required strings
-	tempDay1Str := fmt.Sprintf("%.0f", tempDay1)
-	tempDay2Str := fmt.Sprintf("%.0f", tempDay2)
-	tempDay3Str := fmt.Sprintf("%.0f", tempDay3)
-	forecast1.Text = " " + tempDay1Str + "° "
-	forecast2.Text = " " + tempDay2Str + "° "
-	forecast3.Text = " " + tempDay3Str + "° "
-	forecast1.Refresh()
-	forecast2.Refresh()
-	forecast3.Refresh()
+}
+
+// formatAge formats how old data is in whole minutes, e.g. "45m" or "2h05m"
+func formatAge(age time.Duration) string {
+	minutes := int(age / time.Minute)
+	if minutes < 1 {
+		return "<1m"
+	}
+	if minutes < 60 {
+		return fmt.Sprintf("%dm", minutes)
+	}
+	return fmt.Sprintf("%dh%02dm", minutes/60, minutes%60)
+}
+
+// showStartupError opens a window explaining why the application could not start,
+// configPath is where config.json is looked for
+func showStartupError(err error, configPath string) {
+	message := "The application could not start: " + err.Error()
+	if errors.Is(err, ErrNoAPIKey) {
+		message = "No WeatherAPI key is configured.\n\n" +
+			"Set the " + apiKeyEnv + " environment variable, " +
+			"add \"weatherapi_key\" to " + configPath + ", " +
+			"or start the application with -apikey <key>."
+	}
+
+	myApp := app.New()
+	myWindow := myApp.NewWindow("Weather App")
+	myWindow.Resize(fyne.NewSize(400, 200))
+	messageLabel := widget.NewLabel(message)
+	messageLabel.Wrapping = fyne.TextWrapWord
+	myWindow.SetContent(container.NewPadded(messageLabel))
+	myWindow.ShowAndRun()
+}
+
+// errorMessage turns an error from getCityData into a message that can be shown to the user
+func errorMessage(err error) string {
+	switch {
+	case errors.Is(err, ErrCityNotFound):
+		return "No city matching that name was found."
+	case errors.Is(err, ErrInvalidAPIKey):
+		return "The WeatherAPI key is missing or invalid."
+	case errors.Is(err, ErrQuotaExceeded):
+		return "The WeatherAPI quota has been used up."
+	case errors.Is(err, ErrRateLimited):
+		return "Too many requests were made, the weather will be updated shortly."
+	case errors.Is(err, ErrNetwork), errors.Is(err, context.DeadlineExceeded):
+		return "The weather service could not be reached."
+	case errors.Is(err, ErrMalformedResponse):
+		return "The weather service sent an unexpected response."
+	}
+	return "The weather service returned an error: " + err.Error()
 }
 
 //--NOEL when closing the program just put this line: writeCityNamesToFile(currentState)
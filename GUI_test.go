@@ -0,0 +1,106 @@
+// test file that tests the code in the GUI.go file that does not need a window
+package main
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestLastUpdatedText tests that the "Last Updated" label shows the date of data
+// from an earlier day and how old stale data is
+// tested features - lastUpdatedText
+func TestLastUpdatedText(t *testing.T) {
+	now := time.Date(2023, 10, 11, 9, 0, 0, 0, time.Local)
+	tests := []struct {
+		data WeatherData
+		want string
+	}{
+		{WeatherData{}, "Last Updated: never"},
+		{WeatherData{UpdatedAt: now.Add(-30 * time.Minute)}, "Last Updated: 08:30:00"},
+		{WeatherData{UpdatedAt: now.Add(-48 * time.Hour), Stale: true}, "Last Updated: Oct 9 09:00:00 (stale, 48h00m old)"},
+	}
+	for _, test := range tests {
+		if got := lastUpdatedText(test.data, now); got != test.want {
+			t.Errorf("got %q, want %q", got, test.want)
+		}
+	}
+}
+
+// TestHourlyStart tests that the hourly strip of the replayed fixtures starts at
+// the hour they were recorded, instead of being empty because it is long past
+// tested features - hourlyStart, replayTransport
+func TestHourlyStart(t *testing.T) {
+	replayer := newWeatherAPIProvider("", &http.Client{Transport: replayTransport{Dir: "fixtures"}, Timeout: time.Second})
+	data, err := getCityData(context.Background(), replayer, "Tucson")
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := data.UpdatedAt.AddDate(1, 0, 0)
+	if hours := data.nextHours(hourlyStart(data, false, now), maxForecastHours); len(hours) != 0 {
+		t.Errorf("got %d hours a year after the recording, want none", len(hours))
+	}
+	hours := data.nextHours(hourlyStart(data, true, now), maxForecastHours)
+	if len(hours) == 0 || hours[0].Time.After(data.UpdatedAt) {
+		t.Errorf("replaying got %d hours, want them to start with the recorded hour", len(hours))
+	}
+}
+
+// cacheOnlyCounter is a WeatherProvider that counts the calls made with
+// withCacheOnly before passing them on.
+type cacheOnlyCounter struct {
+	WeatherProvider
+	mu    sync.Mutex
+	calls int
+}
+
+func (c *cacheOnlyCounter) Weather(ctx context.Context, cityName string) (WeatherData, error) {
+	if cacheOnly(ctx) {
+		c.mu.Lock()
+		c.calls++
+		c.mu.Unlock()
+	}
+	return c.WeatherProvider.Weather(ctx, cityName)
+}
+
+// TestLoadStartupWeather tests that the window opens with the cached data when
+// there is a cache, and with data fetched once per city when it is turned off
+// tested features - loadStartupWeather
+func TestLoadStartupWeather(t *testing.T) {
+	fake := newFakeWeatherAPI(t)
+	cityNames := []string{"Tucson", "Kochi"}
+	config := Config{CacheTTL: 10 * time.Minute, StartupTimeout: 5 * time.Second}
+
+	// no cache: every city is fetched straight away
+	provider := &cacheOnlyCounter{WeatherProvider: fake.provider()}
+	state := CurrentState{CityNames: cityNames, WeatherDataMap: make(map[string]WeatherData)}
+	if cached := loadStartupWeather(provider, &state, config); len(cached) != 0 {
+		t.Errorf("got cached cities %v without a cache", cached)
+	}
+	if provider.calls != 0 {
+		t.Errorf("asked for cached data %d times without a cache", provider.calls)
+	}
+	if count := fake.requestCount(""); count != len(cityNames) || len(state.WeatherDataMap) != len(cityNames) {
+		t.Errorf("got %d requests and %d cities, want %d of each", count, len(state.WeatherDataMap), len(cityNames))
+	}
+
+	// with a cache: fetched on the first run, taken from the cache on the next
+	config.CacheDir = t.TempDir()
+	withCache := fake.provider()
+	withCache.Client = &http.Client{Transport: newCachingTransport(config.CacheDir, withCache.Client.Transport, config.CacheTTL)}
+	for run, want := range [][]string{nil, cityNames} {
+		state := CurrentState{CityNames: cityNames, WeatherDataMap: make(map[string]WeatherData)}
+		if cached := loadStartupWeather(withCache, &state, config); !reflect.DeepEqual(cached, want) {
+			t.Errorf("run %d: got cached cities %v, want %v", run, cached, want)
+		}
+		if len(state.WeatherDataMap) != len(cityNames) {
+			t.Errorf("run %d: got data for %d cities", run, len(state.WeatherDataMap))
+		}
+	}
+	if count := fake.requestCount(""); count != 2*len(cityNames) {
+		t.Errorf("got %d requests, want %d", count, 2*len(cityNames))
+	}
+}
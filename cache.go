@@ -0,0 +1,269 @@
+// This file contains the on-disk cache of WeatherAPI responses. It keeps the
+// quota usage down and lets the application start from the last known data
+// while fresh data is fetched in the background.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// errCacheMiss is returned for requests made with withCacheOnly that the cache cannot answer.
+var errCacheMiss = errors.New("not in cache")
+
+// cacheOnlyKey is the context key set by withCacheOnly.
+type cacheOnlyKey struct{}
+
+// withCacheOnly returns a context for requests that must be answered from the
+// cache alone, with any cached response however old, or fail with errCacheMiss.
+func withCacheOnly(ctx context.Context) context.Context {
+	return context.WithValue(ctx, cacheOnlyKey{}, true)
+}
+
+// cacheOnly reports whether ctx was made by withCacheOnly.
+func cacheOnly(ctx context.Context) bool {
+	cacheOnly, _ := ctx.Value(cacheOnlyKey{}).(bool)
+	return cacheOnly
+}
+
+// revalidateKey is the context key set by withRevalidate.
+type revalidateKey struct{}
+
+// withRevalidate returns a context for requests that must reach the WeatherAPI
+// even when a fresh response is cached, such as the refreshes. The response is
+// still cached, and the cached one is still used over a rate limit or quota.
+func withRevalidate(ctx context.Context) context.Context {
+	return context.WithValue(ctx, revalidateKey{}, true)
+}
+
+// revalidate reports whether ctx was made by withRevalidate.
+func revalidate(ctx context.Context) bool {
+	revalidate, _ := ctx.Value(revalidateKey{}).(bool)
+	return revalidate
+}
+
+// cachedFallbackKey is the context key set by withCachedFallback.
+type cachedFallbackKey struct{}
+
+// withCachedFallback returns a context for requests that the cache can answer
+// with an older response when they fail over a limit, so they need not wait long
+// for one, see rateLimitTransport.
+func withCachedFallback(ctx context.Context) context.Context {
+	return context.WithValue(ctx, cachedFallbackKey{}, true)
+}
+
+// hasCachedFallback reports whether ctx was made by withCachedFallback.
+func hasCachedFallback(ctx context.Context) bool {
+	hasCachedFallback, _ := ctx.Value(cachedFallbackKey{}).(bool)
+	return hasCachedFallback
+}
+
+// cacheFallbackHeader is set on the cached responses that stand in for requests
+// that failed over a limit, to the error of that limit, see fallbackLimit.
+const cacheFallbackHeader = "X-Cache-Fallback"
+
+// fallbackLimits are the limits over which the cache answers with older data.
+var fallbackLimits = []error{ErrRateLimited, ErrQuotaExceeded}
+
+// cacheEntry is one cached response as stored on disk.
+type cacheEntry struct {
+	StoredAt time.Time       `json:"stored_at"` // When the response was received.
+	Body     json.RawMessage `json:"body"`      // Body of the 200 response.
+}
+
+// cachingTransport answers requests from the responses cached in Dir while they
+// are younger than TTL, and passes the others on to Base, caching every
+// successful response. Older responses are still used when Base is over a rate
+// limit or quota. The cache survives restarts of the application.
+type cachingTransport struct {
+	Dir  string
+	Base http.RoundTripper
+	TTL  time.Duration // How long responses are used before they are fetched again.
+	now  func() time.Time
+}
+
+// newCachingTransport returns a cachingTransport storing its responses in dir.
+func newCachingTransport(dir string, base http.RoundTripper, ttl time.Duration) *cachingTransport {
+	return &cachingTransport{Dir: dir, Base: base, TTL: ttl, now: time.Now}
+}
+
+// defaultCacheDir returns the per-user directory the cached responses are kept in.
+func defaultCacheDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, appName), nil
+}
+
+// queryKey returns the form of a WeatherAPI query that requests are told apart
+// by: lower case with the spaces collapsed, so that spellings the WeatherAPI
+// treats alike share a key, but nothing else is dropped, since "-33.87,151.21"
+// and "33.87,151.21" are different places. It is escaped for use in file names.
+func queryKey(query string) string {
+	return url.QueryEscape(strings.Join(strings.Fields(strings.ToLower(query)), " "))
+}
+
+// cacheKey returns the name a response is cached under: the endpoint, the
+// query (see queryKey) and the number of forecast days, e.g. "forecast_tucson_3d".
+func cacheKey(request *http.Request) string {
+	query := request.URL.Query()
+	key := strings.TrimSuffix(path.Base(request.URL.Path), ".json") + "_" + queryKey(query.Get("q"))
+	if days := query.Get("days"); days != "" {
+		key += "_" + days + "d"
+	}
+	return key
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *cachingTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	key := cacheKey(request)
+	onlyCached := cacheOnly(request.Context())
+	fresh := func(entry cacheEntry) bool {
+		return t.now().Sub(entry.StoredAt) < t.TTL && !revalidate(request.Context())
+	}
+	entry, err := t.load(key)
+	switch {
+	case err == nil && (onlyCached || fresh(entry)):
+		return cachedResponse(request, entry.Body), nil
+	case err != nil && !os.IsNotExist(err):
+		fmt.Println("Error reading cache:", err)
+	}
+	if onlyCached {
+		return nil, errCacheMiss
+	}
+
+	if entry.Body != nil {
+		request = request.WithContext(withCachedFallback(request.Context()))
+	}
+	response, err := t.Base.RoundTrip(request)
+	if limit := limitError(response, err); entry.Body != nil && limit != nil {
+		// over a limit, old data is better than none, but the caller must know it is old
+		if response != nil {
+			response.Body.Close()
+		}
+		fallback := cachedResponse(request, entry.Body)
+		fallback.Header.Set(cacheFallbackHeader, limit.Error())
+		return fallback, nil
+	}
+	if err != nil || response.StatusCode != http.StatusOK {
+		return response, err
+	}
+	body, err := io.ReadAll(response.Body)
+	response.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	response.Body = io.NopCloser(bytes.NewReader(body))
+	if json.Valid(body) {
+		if err := t.store(key, cacheEntry{StoredAt: t.now(), Body: body}); err != nil {
+			fmt.Println("Error writing cache:", err)
+		}
+	}
+	return response, nil
+}
+
+// limitError returns ErrRateLimited or ErrQuotaExceeded when a request failed
+// because of a client-side limit, the rate limiting of the WeatherAPI or a used
+// up quota, and nil otherwise. The body of the response can still be read
+// afterwards.
+func limitError(response *http.Response, err error) error {
+	if err != nil {
+		for _, limit := range fallbackLimits {
+			if errors.Is(err, limit) {
+				return limit
+			}
+		}
+		return nil
+	}
+	switch response.StatusCode {
+	case http.StatusTooManyRequests:
+		return ErrRateLimited
+	case http.StatusForbidden:
+		// 2007 (quota exceeded) is sent with 403, as are the key errors
+		body, readErr := io.ReadAll(response.Body)
+		response.Body.Close()
+		response.Body = io.NopCloser(bytes.NewReader(body))
+		if readErr == nil && errors.Is(parseAPIError(response.StatusCode, body), ErrQuotaExceeded) {
+			return ErrQuotaExceeded
+		}
+	}
+	return nil
+}
+
+// fallbackLimit returns the limit named by the cacheFallbackHeader of response,
+// or nil when the response did not come from the cache in place of a request
+// that failed over a limit.
+func fallbackLimit(response *http.Response) error {
+	name := response.Header.Get(cacheFallbackHeader)
+	for _, limit := range fallbackLimits {
+		if name != "" && name == limit.Error() {
+			return limit
+		}
+	}
+	return nil
+}
+
+// load reads the entry cached under key.
+func (t *cachingTransport) load(key string) (cacheEntry, error) {
+	var entry cacheEntry
+	contents, err := os.ReadFile(filepath.Join(t.Dir, key+".json"))
+	if err != nil {
+		return entry, err
+	}
+	err = json.Unmarshal(contents, &entry)
+	return entry, err
+}
+
+// store saves entry under key. The entry is written to a temporary file first
+// so that a concurrent load never sees half of it.
+func (t *cachingTransport) store(key string, entry cacheEntry) error {
+	contents, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(t.Dir, 0755); err != nil {
+		return err
+	}
+	temp, err := os.CreateTemp(t.Dir, key+".*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := temp.Write(contents); err != nil {
+		temp.Close()
+		os.Remove(temp.Name())
+		return err
+	}
+	if err := temp.Close(); err != nil {
+		os.Remove(temp.Name())
+		return err
+	}
+	return os.Rename(temp.Name(), filepath.Join(t.Dir, key+".json"))
+}
+
+// cachedResponse builds the response to request from a cached body.
+func cachedResponse(request *http.Request, body []byte) *http.Response {
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": {"application/json"}},
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       request,
+	}
+}
@@ -0,0 +1,171 @@
+// test file that tests the code in the cache.go file
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// TestCachingTransport tests that responses are served from the cache until
+// the TTL runs out, survive a restart, that withRevalidate always reaches the
+// WeatherAPI and that withCacheOnly never does
+// tested features - cachingTransport, withCacheOnly, withRevalidate
+func TestCachingTransport(t *testing.T) {
+	fake := newFakeWeatherAPI(t)
+	dir := t.TempDir()
+	now := time.Now()
+	newProvider := func() WeatherAPIProvider {
+		provider := fake.provider()
+		cache := newCachingTransport(dir, provider.Client.Transport, 10*time.Minute)
+		cache.now = func() time.Time { return now }
+		provider.Client = &http.Client{Transport: cache, Timeout: provider.Client.Timeout}
+		return provider
+	}
+	provider := newProvider()
+	ctx := context.Background()
+
+	// nothing is cached yet
+	if _, err := getCityData(withCacheOnly(ctx), provider, "Tucson"); !errors.Is(err, errCacheMiss) {
+		t.Fatalf("cache only with an empty cache: got %v, want errCacheMiss", err)
+	}
+	if count := fake.requestCount(""); count != 0 {
+		t.Errorf("cache only sent %d requests", count)
+	}
+
+	first, err := getCityData(ctx, provider, "Tucson")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// a different spelling of the same city is the same cache entry
+	second, err := getCityData(ctx, provider, " tucson")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.TempC0 != second.TempC0 || !reflect.DeepEqual(first.Forecast, second.Forecast) {
+		t.Errorf("cached data differs: %+v, %+v", first, second)
+	}
+	if count := fake.requestCount(""); count != 1 {
+		t.Errorf("got %d requests, want 1", count)
+	}
+
+	// within the TTL the cache answers, after it the WeatherAPI
+	now = now.Add(5 * time.Minute)
+	if _, err := getCityData(ctx, provider, "Tucson"); err != nil {
+		t.Fatal(err)
+	}
+	now = now.Add(10 * time.Minute)
+	if _, err := getCityData(ctx, provider, "Tucson"); err != nil {
+		t.Fatal(err)
+	}
+	if count := fake.requestCount(""); count != 2 {
+		t.Errorf("after 15 minutes got %d requests, want 2", count)
+	}
+
+	// refreshes revalidate within the TTL, and the response they get is cached
+	if _, err := getCityData(withRevalidate(ctx), provider, "Tucson"); err != nil {
+		t.Fatal(err)
+	}
+	if count := fake.requestCount(""); count != 3 {
+		t.Errorf("revalidating got %d requests, want 3", count)
+	}
+	now = now.Add(9 * time.Minute)
+	if _, err := getCityData(ctx, provider, "Tucson"); err != nil {
+		t.Fatal(err)
+	}
+	if count := fake.requestCount(""); count != 3 {
+		t.Errorf("the revalidated response was not cached: %d requests, want 3", count)
+	}
+
+	// after a restart the cache is still there, and cache only serves it however old
+	now = now.Add(24 * time.Hour)
+	provider = newProvider()
+	cached, err := getCityData(withCacheOnly(ctx), provider, "Tucson")
+	if err != nil || cached.ForecastErr != nil {
+		t.Fatalf("cache only after a restart: %v, forecast %v", err, cached.ForecastErr)
+	}
+	if cached.TempC0 != first.TempC0 {
+		t.Errorf("cached temperature %v, want %v", cached.TempC0, first.TempC0)
+	}
+	if count := fake.requestCount(""); count != 3 {
+		t.Errorf("cache only sent requests: %d in total, want 3", count)
+	}
+
+	// errors are not cached
+	if _, err := getCityData(ctx, provider, "Atlantis"); !errors.Is(err, ErrCityNotFound) {
+		t.Fatalf("got %v, want ErrCityNotFound", err)
+	}
+	if _, err := getCityData(withCacheOnly(ctx), provider, "Atlantis"); !errors.Is(err, errCacheMiss) {
+		t.Errorf("cache only for a failed city: got %v, want errCacheMiss", err)
+	}
+}
+
+// TestCachedFallback tests that a refresh answered from the cache because the
+// WeatherAPI refused it over a limit shows the older data as stale, with the
+// limit as the reason it was not updated
+// tested features - cachingTransport, WeatherAPIProvider.Weather, keepLastGood
+func TestCachedFallback(t *testing.T) {
+	fake := newFakeWeatherAPI(t)
+	provider := fake.provider()
+	provider.Client = &http.Client{Transport: newCachingTransport(t.TempDir(), provider.Client.Transport, 10*time.Minute)}
+	ctx := withRevalidate(context.Background())
+
+	previous, err := getCityData(ctx, provider, "Tucson")
+	if err != nil || previous.Stale {
+		t.Fatalf("first refresh: %+v, %v", previous, err)
+	}
+	quota, _ := os.ReadFile(filepath.Join("testdata", "weatherapi", "error_2007.json"))
+	for _, test := range []struct {
+		response fakeResponse
+		want     error
+	}{
+		{fakeResponse{status: http.StatusForbidden, body: string(quota)}, ErrQuotaExceeded},
+		{fakeResponse{status: http.StatusTooManyRequests, body: "slow down"}, ErrRateLimited},
+	} {
+		fake.queue(test.response)
+		fresh, err := getCityData(ctx, provider, "Tucson")
+		if err != nil {
+			t.Fatalf("over a limit with cached data: %v", err)
+		}
+		if !fresh.Stale || !errors.Is(fresh.RefreshErr, test.want) || fresh.TempC0 != previous.TempC0 {
+			t.Errorf("got stale %v, error %v, want the cached data, stale, with %v", fresh.Stale, fresh.RefreshErr, test.want)
+		}
+		// the refreshes keep it as it is, still stale
+		if kept := keepLastGood(previous, fresh, err); !kept.Stale || !errors.Is(kept.RefreshErr, test.want) {
+			t.Errorf("after the refresh got stale %v, error %v", kept.Stale, kept.RefreshErr)
+		}
+	}
+
+	// the next refresh that gets through is fresh again
+	if fresh, err := getCityData(ctx, provider, "Tucson"); err != nil || fresh.Stale || fresh.RefreshErr != nil {
+		t.Errorf("refresh after the limits: %+v, %v", fresh, err)
+	}
+}
+
+// TestCacheKey tests that spellings of the same city share a cache entry, and
+// that coordinates differing only in their sign do not
+// tested features - cacheKey, queryKey
+func TestCacheKey(t *testing.T) {
+	key := func(query string) string {
+		request, err := http.NewRequest(http.MethodGet, "http://host/v1/forecast.json?key=k&days=3&q="+url.QueryEscape(query), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cacheKey(request)
+	}
+	if got := key("Tucson"); got != "forecast_tucson_3d" {
+		t.Errorf("got key %q, want forecast_tucson_3d", got)
+	}
+	if key(" New  York") != key("new york") {
+		t.Errorf("spellings of New York have different keys: %q, %q", key(" New  York"), key("new york"))
+	}
+	if key("-33.87,151.21") == key("33.87,151.21") {
+		t.Errorf("different coordinates share the key %q", key("-33.87,151.21"))
+	}
+}
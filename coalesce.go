@@ -0,0 +1,77 @@
+// This file contains the coalescing of concurrent WeatherAPI requests, so that
+// callers asking for the same data at the same time share one request.
+
+package main
+
+import (
+	"context"
+	"sync"
+)
+
+// flightGroup runs one request per key at a time. Callers asking for a key that
+// is already being requested wait for that request and share its result.
+type flightGroup struct {
+	mu    sync.Mutex
+	calls map[string]*flight
+}
+
+// flight is a request in progress and the callers waiting for it.
+type flight struct {
+	done    chan struct{} // Closed when body and err are set.
+	body    []byte
+	err     error
+	waiters int                // Callers still waiting for the result.
+	cancel  context.CancelFunc // Cancels the request once no caller waits for it.
+}
+
+// newFlightGroup returns an empty flightGroup.
+func newFlightGroup() *flightGroup {
+	return &flightGroup{calls: make(map[string]*flight)}
+}
+
+// do returns the result of fn for key, calling fn only when no call for key is
+// in progress already. fn gets a context that carries the values of ctx but is
+// only cancelled when every caller waiting for it has given up, so one caller
+// going away does not fail the others. A nil flightGroup just calls fn.
+func (g *flightGroup) do(ctx context.Context, key string, fn func(ctx context.Context) ([]byte, error)) ([]byte, error) {
+	if g == nil {
+		return fn(ctx)
+	}
+
+	g.mu.Lock()
+	f, ok := g.calls[key]
+	if !ok {
+		flightCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+		f = &flight{done: make(chan struct{}), cancel: cancel}
+		g.calls[key] = f
+		go func() {
+			f.body, f.err = fn(flightCtx)
+			cancel()
+			g.mu.Lock()
+			if g.calls[key] == f {
+				delete(g.calls, key)
+			}
+			g.mu.Unlock()
+			close(f.done)
+		}()
+	}
+	f.waiters++
+	g.mu.Unlock()
+
+	select {
+	case <-f.done:
+		return f.body, f.err
+	case <-ctx.Done():
+		g.mu.Lock()
+		f.waiters--
+		// the last caller gone: stop the request, and let later callers start a new one
+		if f.waiters == 0 {
+			f.cancel()
+			if g.calls[key] == f {
+				delete(g.calls, key)
+			}
+		}
+		g.mu.Unlock()
+		return nil, ctx.Err()
+	}
+}
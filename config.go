@@ -0,0 +1,182 @@
+// This file contains the settings the application is started with and how they
+// are resolved from the environment, the user config file and command-line flags.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// appName is the name of the directory the application keeps its files in.
+const appName = "go-weather-app"
+
+// apiKeyEnv is the environment variable checked first for the WeatherAPI key.
+const apiKeyEnv = "WEATHERAPI_KEY"
+
+// ErrNoAPIKey is returned by loadConfig when no WeatherAPI key is configured anywhere.
+var ErrNoAPIKey = errors.New("no WeatherAPI key configured")
+
+// Config holds the settings the application is started with.
+type Config struct {
+	APIKey          string        // Key for the WeatherAPI.
+	BaseURL         string        // Where the WeatherAPI endpoints are served, defaultBaseURL unless testing.
+	ConnectTimeout  time.Duration // How long to wait for a connection to the WeatherAPI.
+	RequestTimeout  time.Duration // How long a whole request to the WeatherAPI may take.
+	StartupTimeout  time.Duration // How long to wait for the cities at startup before opening the window.
+	Retry           RetryPolicy   // How transient WeatherAPI failures are retried.
+	RefreshInterval time.Duration // How often every tracked city is refreshed.
+	ForecastDays    int           // Days of forecast shown, 1 to maxForecastDays.
+	RecordDir       string        // Directory WeatherAPI responses are recorded into, empty to not record.
+	ReplayDir       string        // Directory recorded responses are replayed from instead of calling the WeatherAPI.
+	CacheDir        string        // Directory WeatherAPI responses are cached in, empty to not cache.
+	CacheTTL        time.Duration // How long cached weather data is used before fetching it again, refreshes always fetch.
+	RateLimit       float64       // WeatherAPI calls allowed per minute on average.
+	Burst           int           // WeatherAPI calls allowed at once above the average rate.
+	MaxConcurrent   int           // WeatherAPI calls allowed in flight at a time.
+	DailyQuota      int           // WeatherAPI calls allowed per day, 0 for no limit.
+	MonthlyQuota    int           // WeatherAPI calls allowed per month, 0 for no limit.
+	QuotaWarning    float64       // Fraction of a quota after which the GUI warns about the usage.
+	ConfigDir       string        // Directory config.json, usage.json and cityNames.txt live in.
+}
+
+// Names of the files kept in Config.ConfigDir.
+const (
+	configFileName    = "config.json"   // settings such as the WeatherAPI key, see configFile
+	usageFileName     = "usage.json"    // WeatherAPI calls made, see quotaTracker
+	cityNamesFileName = "cityNames.txt" // tracked cities, one per line
+)
+
+// configFile is the layout of config.json in the user config directory.
+type configFile struct {
+	WeatherAPIKey string `json:"weatherapi_key"`
+}
+
+// appConfigDir returns the per-user directory the application's config files live in.
+func appConfigDir() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, appName), nil
+}
+
+// configPath returns the path of the file name in the config directory.
+func (c Config) configPath(name string) string {
+	return filepath.Join(c.ConfigDir, name)
+}
+
+// loadConfig builds the Config from the command-line arguments (without the program name).
+// The API key is taken from the WEATHERAPI_KEY environment variable, then the config
+// file, then the -apikey flag; ErrNoAPIKey is returned when none of them set it,
+// unless the responses are replayed; the Config returned with it still has
+// ConfigDir set, to tell the user where the config file goes.
+func loadConfig(args []string) (Config, error) {
+	flags := flag.NewFlagSet(appName, flag.ContinueOnError)
+	apiKeyFlag := flags.String("apikey", "", "WeatherAPI key, used when "+apiKeyEnv+" and the config file do not set one")
+	var config Config
+	flags.StringVar(&config.BaseURL, "base-url", defaultBaseURL, "base URL of the WeatherAPI, e.g. a local fake server")
+	flags.DurationVar(&config.ConnectTimeout, "connect-timeout", 5*time.Second, "timeout for connecting to the WeatherAPI")
+	flags.DurationVar(&config.RequestTimeout, "timeout", 15*time.Second, "timeout for a whole request to the WeatherAPI")
+	flags.DurationVar(&config.StartupTimeout, "startup-timeout", 10*time.Second, "how long to wait for weather data before opening the window")
+	flags.IntVar(&config.Retry.Attempts, "retries", 3, "attempts per WeatherAPI request for transient failures")
+	flags.DurationVar(&config.Retry.BaseDelay, "retry-delay", 500*time.Millisecond, "delay before the first retry, doubled for each further retry")
+	flags.DurationVar(&config.Retry.MaxDelay, "retry-max-delay", 10*time.Second, "maximum delay between retries")
+	flags.DurationVar(&config.RefreshInterval, "refresh", 5*time.Minute, "how often every tracked city is refreshed")
+	flags.IntVar(&config.ForecastDays, "days", defaultForecastDays, fmt.Sprintf("days of forecast shown, 1 to %d (the free WeatherAPI plan gives 3)", maxForecastDays))
+	flags.StringVar(&config.RecordDir, "record", "", "record WeatherAPI responses as fixtures into this directory")
+	flags.StringVar(&config.ReplayDir, "replay", "", "replay recorded fixtures from this directory instead of calling the WeatherAPI")
+	cacheDir, err := defaultCacheDir()
+	if err != nil {
+		cacheDir = ""
+	}
+	flags.StringVar(&config.CacheDir, "cache-dir", cacheDir, "directory WeatherAPI responses are cached in, empty to not cache them")
+	flags.DurationVar(&config.CacheTTL, "cache-ttl", 10*time.Minute, "how long cached weather data is used instead of fetching it, except by the refreshes")
+	flags.Float64Var(&config.RateLimit, "rate-limit", 60, "WeatherAPI calls allowed per minute")
+	flags.IntVar(&config.Burst, "burst", 10, "WeatherAPI calls allowed at once above the rate limit")
+	flags.IntVar(&config.MaxConcurrent, "max-concurrent", 4, "WeatherAPI calls allowed in flight at a time")
+	flags.IntVar(&config.DailyQuota, "daily-quota", 0, "WeatherAPI calls allowed per day, 0 for no limit")
+	flags.IntVar(&config.MonthlyQuota, "monthly-quota", 1000000, "WeatherAPI calls allowed per month, 0 for no limit")
+	flags.Float64Var(&config.QuotaWarning, "quota-warning", 0.8, "fraction of a quota after which a warning is shown")
+	configDir, err := appConfigDir()
+	if err != nil {
+		configDir = ""
+	}
+	flags.StringVar(&config.ConfigDir, "config", configDir, "directory of "+configFileName+", "+usageFileName+" and "+cityNamesFileName)
+	if err := flags.Parse(args); err != nil {
+		return Config{}, err
+	}
+	if config.RecordDir != "" && config.ReplayDir != "" {
+		return Config{}, errors.New("-record and -replay cannot be used together")
+	}
+	if config.ForecastDays < 1 || config.ForecastDays > maxForecastDays {
+		return Config{}, fmt.Errorf("-days must be between 1 and %d", maxForecastDays)
+	}
+	if config.RefreshInterval <= 0 {
+		return Config{}, errors.New("-refresh must be greater than 0")
+	}
+	if config.RateLimit <= 0 {
+		return Config{}, errors.New("-rate-limit must be greater than 0")
+	}
+	if config.ConfigDir == "" {
+		return Config{}, errors.New("no user config directory found, set one with -config")
+	}
+	// recording must see every response and replaying never needs the network
+	if config.RecordDir != "" || config.ReplayDir != "" {
+		config.CacheDir = ""
+	}
+
+	config.APIKey, err = resolveAPIKey(os.Getenv(apiKeyEnv), config.configPath(configFileName), *apiKeyFlag)
+	// replaying needs no key, nothing is sent to the WeatherAPI
+	if errors.Is(err, ErrNoAPIKey) && config.ReplayDir != "" {
+		err = nil
+	}
+	if errors.Is(err, ErrNoAPIKey) {
+		return Config{ConfigDir: config.ConfigDir}, err
+	}
+	if err != nil {
+		return Config{}, err
+	}
+	return config, nil
+}
+
+// resolveAPIKey returns the first non-empty key out of envKey, the config file at
+// path and flagKey. A missing config file is skipped, an unreadable one is an error.
+func resolveAPIKey(envKey, path, flagKey string) (string, error) {
+	if key := strings.TrimSpace(envKey); key != "" {
+		return key, nil
+	}
+	if path != "" {
+		contents, err := os.ReadFile(path)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return "", err
+		}
+		if err == nil {
+			var file configFile
+			if err := json.Unmarshal(contents, &file); err != nil {
+				return "", fmt.Errorf("reading %s: %w", path, err)
+			}
+			if key := strings.TrimSpace(file.WeatherAPIKey); key != "" {
+				return key, nil
+			}
+		}
+	}
+	if key := strings.TrimSpace(flagKey); key != "" {
+		return key, nil
+	}
+	return "", ErrNoAPIKey
+}
+
+// redact replaces every occurrence of secret in s so that it can be logged safely.
+func redact(s, secret string) string {
+	if secret == "" {
+		return s
+	}
+	return strings.ReplaceAll(s, secret, "REDACTED")
+}
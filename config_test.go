@@ -0,0 +1,111 @@
+// test file that tests the code in the config.go file
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestResolveAPIKey tests that the API key is taken from the environment first,
+// then the config file, then the command-line flag
+// tested features - resolveAPIKey
+func TestResolveAPIKey(t *testing.T) {
+	dir := t.TempDir()
+	withKey := filepath.Join(dir, "config.json")
+	if err := os.WriteFile(withKey, []byte(`{"weatherapi_key": "filekey"}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	missing := filepath.Join(dir, "missing.json")
+
+	tests := []struct {
+		env, path, flag string
+		want            string
+	}{
+		{"envkey", withKey, "flagkey", "envkey"},
+		{"", withKey, "flagkey", "filekey"},
+		{"", missing, "flagkey", "flagkey"},
+		{" ", missing, "flagkey", "flagkey"},
+	}
+	for _, test := range tests {
+		got, err := resolveAPIKey(test.env, test.path, test.flag)
+		if err != nil || got != test.want {
+			t.Errorf("resolveAPIKey(%q, %q, %q) = %q, %v, want %q", test.env, test.path, test.flag, got, err, test.want)
+		}
+	}
+
+	if _, err := resolveAPIKey("", missing, ""); !errors.Is(err, ErrNoAPIKey) {
+		t.Errorf("expected ErrNoAPIKey without any key, got %v", err)
+	}
+
+	malformed := filepath.Join(dir, "malformed.json")
+	if err := os.WriteFile(malformed, []byte(`weatherapi_key = x`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := resolveAPIKey("", malformed, "flagkey"); err == nil {
+		t.Errorf("expected an error for a malformed config file")
+	}
+}
+
+// TestConfigDir tests that -config moves the directory the config file is read from,
+// and that the error for a missing key still says where that directory is
+// tested features - loadConfig, Config.configPath
+func TestConfigDir(t *testing.T) {
+	t.Setenv(apiKeyEnv, "")
+	dir := t.TempDir()
+
+	config, err := loadConfig([]string{"-config", dir})
+	if !errors.Is(err, ErrNoAPIKey) || config.ConfigDir != dir {
+		t.Fatalf("without a key got %v with config dir %q, want ErrNoAPIKey with %q", err, config.ConfigDir, dir)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, configFileName), []byte(`{"weatherapi_key": "filekey"}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	config, err = loadConfig([]string{"-config", dir})
+	if err != nil || config.APIKey != "filekey" {
+		t.Fatalf("got key %q, error %v, want the key from %s", config.APIKey, err, configFileName)
+	}
+	if path := config.configPath(cityNamesFileName); path != filepath.Join(dir, cityNamesFileName) {
+		t.Errorf("city list at %s, want it in %s", path, dir)
+	}
+}
+
+// TestRefreshInterval tests that a refresh interval that would make the
+// scheduler spin is refused
+// tested features - loadConfig
+func TestRefreshInterval(t *testing.T) {
+	for _, refresh := range []string{"0", "-1m"} {
+		if _, err := loadConfig([]string{"-config", t.TempDir(), "-replay", t.TempDir(), "-refresh", refresh}); err == nil {
+			t.Errorf("-refresh %s accepted", refresh)
+		}
+	}
+	if _, err := loadConfig([]string{"-config", t.TempDir(), "-replay", t.TempDir(), "-refresh", "1m"}); err != nil {
+		t.Errorf("-refresh 1m refused: %v", err)
+	}
+}
+
+// TestRateLimit tests that a rate limit that would refuse every call is refused
+// tested features - loadConfig
+func TestRateLimit(t *testing.T) {
+	for _, rate := range []string{"0", "-5"} {
+		if _, err := loadConfig([]string{"-config", t.TempDir(), "-replay", t.TempDir(), "-rate-limit", rate}); err == nil {
+			t.Errorf("-rate-limit %s accepted", rate)
+		}
+	}
+	if _, err := loadConfig([]string{"-config", t.TempDir(), "-replay", t.TempDir(), "-rate-limit", "0.5"}); err != nil {
+		t.Errorf("-rate-limit 0.5 refused: %v", err)
+	}
+}
+
+// TestRedact tests that the API key is removed from strings before they are logged
+// tested features - redact
+func TestRedact(t *testing.T) {
+	if got := redact("http://host/v1/current.json?key=secret&q=x", "secret"); got != "http://host/v1/current.json?key=REDACTED&q=x" {
+		t.Errorf("key not redacted: %s", got)
+	}
+	if got := redact("nothing to hide", ""); got != "nothing to hide" {
+		t.Errorf("redact with an empty secret changed the string: %s", got)
+	}
+}
@@ -0,0 +1,67 @@
+// This file contains the errors returned when weather data cannot be fetched.
+// Callers can tell the failures apart with errors.Is, for example
+// errors.Is(err, ErrCityNotFound).
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+var (
+	ErrCityNotFound      = errors.New("city not found")     // No location matches the requested city name.
+	ErrInvalidAPIKey     = errors.New("invalid API key")    // The API key is missing, invalid or disabled.
+	ErrQuotaExceeded     = errors.New("API quota exceeded") // The API key has used up its calls for the day or month.
+	ErrRateLimited       = errors.New("rate limited")       // Too many calls were made in a short time.
+	ErrNetwork           = errors.New("network failure")    // The API could not be reached or the response was cut off.
+	ErrMalformedResponse = errors.New("malformed response") // The API answered with a payload that could not be decoded.
+)
+
+// APIError is an error reported by the WeatherAPI in a non-200 response.
+// It wraps one of the Err* errors above when the error code is a known one.
+type APIError struct {
+	StatusCode int           // HTTP status code of the response.
+	Code       int           // WeatherAPI error code, 0 when the body had no error payload.
+	Message    string        // Error message sent by the API.
+	RetryAfter time.Duration // Delay asked for by the Retry-After header, 0 when absent.
+}
+
+// Error implements the error interface.
+func (e *APIError) Error() string {
+	if e.Code == 0 {
+		return fmt.Sprintf("weatherapi: status %d: %s", e.StatusCode, e.Message)
+	}
+	return fmt.Sprintf("weatherapi: error %d (status %d): %s", e.Code, e.StatusCode, e.Message)
+}
+
+// Unwrap maps the WeatherAPI error codes (https://www.weatherapi.com/docs/#intro-error-codes)
+// to the errors above.
+func (e *APIError) Unwrap() error {
+	switch e.Code {
+	case 1003, 1006:
+		return ErrCityNotFound
+	case 1002, 2006, 2008, 2009:
+		return ErrInvalidAPIKey
+	case 2007:
+		return ErrQuotaExceeded
+	}
+	return nil
+}
+
+// parseAPIError builds the error for a non-200 response from its status code and body.
+func parseAPIError(statusCode int, body []byte) *APIError {
+	var payload struct {
+		Error *struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil || payload.Error == nil {
+		return &APIError{StatusCode: statusCode, Message: http.StatusText(statusCode)}
+	}
+	return &APIError{StatusCode: statusCode, Code: payload.Error.Code, Message: payload.Error.Message}
+}
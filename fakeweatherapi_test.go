@@ -0,0 +1,132 @@
+// test file with a fake WeatherAPI used by the tests instead of the real one,
+// so that they run offline and always see the same data
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeAPIKey is the only key the fake WeatherAPI accepts.
+const fakeAPIKey = "testkey"
+
+// fakeResponse is a canned response the fake WeatherAPI sends instead of a fixture.
+type fakeResponse struct {
+	status int
+	header http.Header
+	body   string
+}
+
+// fakeWeatherAPI is an httptest server that serves the recorded responses in
+// testdata/weatherapi the way the WeatherAPI would: forecast_<city>.json for
+// known cities, and the WeatherAPI error payloads for unknown cities (1006),
+// missing keys (1002) and wrong keys (2006).
+type fakeWeatherAPI struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	requests map[string]int // requests per endpoint, e.g. "forecast.json"
+	queued   []fakeResponse // sent, in order, before falling back to the fixtures
+	delay    time.Duration  // how long every response is held back
+}
+
+// newFakeWeatherAPI starts a fake WeatherAPI that is closed when the test ends.
+func newFakeWeatherAPI(t testing.TB) *fakeWeatherAPI {
+	fake := &fakeWeatherAPI{requests: make(map[string]int)}
+	fake.Server = httptest.NewServer(http.HandlerFunc(fake.serve))
+	t.Cleanup(fake.Close)
+	return fake
+}
+
+// provider returns a WeatherAPIProvider that talks to the fake with the accepted key.
+func (f *fakeWeatherAPI) provider() WeatherAPIProvider {
+	provider := newWeatherAPIProvider(fakeAPIKey, newHTTPClient(time.Second, 5*time.Second))
+	provider.BaseURL = f.URL + "/v1"
+	return provider
+}
+
+// queue makes the fake send responses, in order, to the next requests instead of the fixtures.
+func (f *fakeWeatherAPI) queue(responses ...fakeResponse) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.queued = append(f.queued, responses...)
+}
+
+// setDelay makes the fake wait for delay before answering each request.
+func (f *fakeWeatherAPI) setDelay(delay time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.delay = delay
+}
+
+// requestCount returns how many requests were made to endpoint, or to all endpoints when endpoint is empty.
+func (f *fakeWeatherAPI) requestCount(endpoint string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if endpoint != "" {
+		return f.requests[endpoint]
+	}
+	total := 0
+	for _, count := range f.requests {
+		total += count
+	}
+	return total
+}
+
+func (f *fakeWeatherAPI) serve(w http.ResponseWriter, r *http.Request) {
+	endpoint := strings.TrimPrefix(r.URL.Path, "/v1/")
+	f.mu.Lock()
+	f.requests[endpoint]++
+	var next *fakeResponse
+	if len(f.queued) > 0 {
+		next = &f.queued[0]
+		f.queued = f.queued[1:]
+	}
+	delay := f.delay
+	f.mu.Unlock()
+	time.Sleep(delay)
+
+	w.Header().Set("Content-Type", "application/json")
+	if next != nil {
+		for name, values := range next.header {
+			w.Header()[name] = values
+		}
+		w.WriteHeader(next.status)
+		w.Write([]byte(next.body))
+		return
+	}
+
+	query := r.URL.Query()
+	switch {
+	case endpoint != "forecast.json":
+		serveFixture(w, http.StatusBadRequest, "error_9999.json")
+	case query.Get("key") == "":
+		serveFixture(w, http.StatusUnauthorized, "error_1002.json")
+	case query.Get("key") != fakeAPIKey:
+		serveFixture(w, http.StatusUnauthorized, "error_2006.json")
+	default:
+		name := strings.TrimSuffix(endpoint, ".json") + "_" + queryKey(query.Get("q")) + ".json"
+		if _, err := os.Stat(filepath.Join("testdata", "weatherapi", name)); err != nil {
+			serveFixture(w, http.StatusBadRequest, "error_1006.json")
+			return
+		}
+		serveFixture(w, http.StatusOK, name)
+	}
+}
+
+// serveFixture sends the file name from testdata/weatherapi with status.
+func serveFixture(w http.ResponseWriter, status int, name string) {
+	body, err := os.ReadFile(filepath.Join("testdata", "weatherapi", name))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(status)
+	w.Write(body)
+}
@@ -0,0 +1,121 @@
+// This file contains the HTTP transports that record WeatherAPI responses into
+// fixture files and replay them, so that tests and demo runs of the application
+// can work offline with realistic data (see the -record and -replay flags).
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// fixture is one recorded WeatherAPI response as stored on disk.
+type fixture struct {
+	Endpoint string          `json:"endpoint"` // Endpoint that was requested, e.g. "forecast.json".
+	Query    string          `json:"query"`    // City name that was requested, as sent.
+	Status   int             `json:"status"`   // HTTP status code of the response.
+	Body     json.RawMessage `json:"body"`     // Response body, with the API key scrubbed.
+}
+
+// fixturePath returns the file in dir that holds the response to a request,
+// e.g. dir/forecast_tucson.json for forecast.json?q=Tucson. The query is named
+// by its cache key (see queryKey), so cities only share a fixture when they
+// would share a cached response.
+func fixturePath(dir string, request *http.Request) string {
+	endpoint := strings.TrimSuffix(path.Base(request.URL.Path), ".json")
+	return filepath.Join(dir, endpoint+"_"+queryKey(request.URL.Query().Get("q"))+".json")
+}
+
+// recordingTransport passes requests on to Base and saves every JSON response
+// as a fixture in Dir, with Secret (the API key) scrubbed from it.
+type recordingTransport struct {
+	Dir    string
+	Base   http.RoundTripper
+	Secret string
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *recordingTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	response, err := t.Base.RoundTrip(request)
+	if err != nil {
+		return nil, err
+	}
+	body, err := io.ReadAll(response.Body)
+	response.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	response.Body = io.NopCloser(bytes.NewReader(body))
+
+	// only JSON bodies can be stored, anything else (an HTML error page) is not recorded
+	scrubbed := []byte(redact(string(body), t.Secret))
+	if !json.Valid(scrubbed) {
+		return response, nil
+	}
+	recorded := fixture{
+		Endpoint: path.Base(request.URL.Path),
+		Query:    request.URL.Query().Get("q"),
+		Status:   response.StatusCode,
+		Body:     scrubbed,
+	}
+	if err := writeFixture(fixturePath(t.Dir, request), recorded); err != nil {
+		fmt.Println("Error recording response:", err)
+	}
+	return response, nil
+}
+
+// writeFixture saves recorded to the file at path, creating its directory.
+func writeFixture(path string, recorded fixture) error {
+	contents, err := json.MarshalIndent(recorded, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(contents, '\n'), 0644)
+}
+
+// replayTransport answers requests from the fixtures in Dir without any network
+// access. Requests without a fixture get the WeatherAPI's "No matching location
+// found" error, as an unknown city would.
+type replayTransport struct {
+	Dir string
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t replayTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	if request.Body != nil {
+		request.Body.Close()
+	}
+	recorded := fixture{
+		Status: http.StatusBadRequest,
+		Body:   json.RawMessage(`{"error":{"code":1006,"message":"No matching location found."}}`),
+	}
+	contents, err := os.ReadFile(fixturePath(t.Dir, request))
+	if err == nil {
+		err = json.Unmarshal(contents, &recorded)
+	}
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("replaying %s: %w", fixturePath(t.Dir, request), err)
+	}
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", recorded.Status, http.StatusText(recorded.Status)),
+		StatusCode:    recorded.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": {"application/json"}},
+		Body:          io.NopCloser(bytes.NewReader(recorded.Body)),
+		ContentLength: int64(len(recorded.Body)),
+		Request:       request,
+	}, nil
+}
@@ -0,0 +1,76 @@
+// test file that tests the code in the fixtures.go file
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestRecordReplay tests that responses recorded from the fake WeatherAPI are
+// replayed offline as the same weather data, with the key scrubbed
+// tested features - recordingTransport, replayTransport, fixturePath
+func TestRecordReplay(t *testing.T) {
+	dir := t.TempDir()
+	fake := newFakeWeatherAPI(t)
+	recorder := fake.provider()
+	recorder.Client.Transport = &recordingTransport{Dir: dir, Base: recorder.Client.Transport, Secret: fakeAPIKey}
+
+	recorded, err := getCityData(context.Background(), recorder, "Kochi")
+	if err != nil {
+		t.Fatalf("unexpected error recording: %v", err)
+	}
+	if _, err := getCityData(context.Background(), recorder, "Atlantis"); !errors.Is(err, ErrCityNotFound) {
+		t.Fatalf("expected ErrCityNotFound recording an unknown city, got %v", err)
+	}
+
+	for _, name := range []string{"forecast_kochi.json", "forecast_atlantis.json"} {
+		contents, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Errorf("fixture %s not recorded: %v", name, err)
+			continue
+		}
+		if strings.Contains(string(contents), fakeAPIKey) {
+			t.Errorf("fixture %s contains the API key", name)
+		}
+	}
+
+	// replay without the fake server, which would fail the test if it were called
+	fake.Close()
+	replayer := newWeatherAPIProvider("", &http.Client{Transport: replayTransport{Dir: dir}, Timeout: time.Second})
+	replayed, err := getCityData(context.Background(), replayer, "kochi ")
+	if err != nil {
+		t.Fatalf("unexpected error replaying: %v", err)
+	}
+	if replayed.CityName != "kochi " || replayed.TempC0 != recorded.TempC0 || !reflect.DeepEqual(replayed.Forecast, recorded.Forecast) {
+		t.Errorf("replayed data %+v differs from recorded %+v", replayed, recorded)
+	}
+	if _, err := getCityData(context.Background(), replayer, "Atlantis"); !errors.Is(err, ErrCityNotFound) {
+		t.Errorf("expected the recorded ErrCityNotFound, got %v", err)
+	}
+	if _, err := getCityData(context.Background(), replayer, "Tokyo"); !errors.Is(err, ErrCityNotFound) {
+		t.Errorf("expected ErrCityNotFound for a city without fixtures, got %v", err)
+	}
+
+	tests := map[string]string{
+		"Tucson":      "forecast_tucson.json",
+		" New  York ": "forecast_new+york.json",
+		"St. Louis":   "forecast_st.+louis.json",
+		"St Louis":    "forecast_st+louis.json",
+		"-33.9,151.2": "forecast_-33.9%2C151.2.json",
+		"33.9,151.2":  "forecast_33.9%2C151.2.json",
+	}
+	for query, want := range tests {
+		request, _ := http.NewRequest(http.MethodGet, "https://api.weatherapi.com/v1/forecast.json?q="+url.QueryEscape(query), nil)
+		if got := fixturePath(dir, request); got != filepath.Join(dir, want) {
+			t.Errorf("fixturePath(%q) = %q, want %q", query, got, want)
+		}
+	}
+}
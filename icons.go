@@ -0,0 +1,102 @@
+// This file contains the weather condition icons, bundled with the application
+// so that they are shown without fetching anything, and their mapping from the
+// WeatherAPI condition codes.
+
+package main
+
+import (
+	"embed"
+	"fmt"
+
+	"fyne.io/fyne/v2"
+)
+
+//go:embed icons/*.svg
+var iconFiles embed.FS
+
+// conditionIcons maps the WeatherAPI condition codes to the name of their icon,
+// see https://www.weatherapi.com/docs/weather_conditions.json. The icons with a
+// day and a night variant are named without the "-day" or "-night" suffix.
+var conditionIcons = map[int]string{
+	1000: "clear",         // Sunny / Clear
+	1003: "partly-cloudy", // Partly cloudy
+	1006: "cloudy",        // Cloudy
+	1009: "cloudy",        // Overcast
+	1030: "fog",           // Mist
+	1063: "rain",          // Patchy rain possible
+	1066: "snow",          // Patchy snow possible
+	1069: "sleet",         // Patchy sleet possible
+	1072: "drizzle",       // Patchy freezing drizzle possible
+	1087: "thunder",       // Thundery outbreaks possible
+	1114: "snow",          // Blowing snow
+	1117: "snow",          // Blizzard
+	1135: "fog",           // Fog
+	1147: "fog",           // Freezing fog
+	1150: "drizzle",       // Patchy light drizzle
+	1153: "drizzle",       // Light drizzle
+	1168: "drizzle",       // Freezing drizzle
+	1171: "drizzle",       // Heavy freezing drizzle
+	1180: "rain",          // Patchy light rain
+	1183: "rain",          // Light rain
+	1186: "rain",          // Moderate rain at times
+	1189: "rain",          // Moderate rain
+	1192: "rain",          // Heavy rain at times
+	1195: "rain",          // Heavy rain
+	1198: "sleet",         // Light freezing rain
+	1201: "sleet",         // Moderate or heavy freezing rain
+	1204: "sleet",         // Light sleet
+	1207: "sleet",         // Moderate or heavy sleet
+	1210: "snow",          // Patchy light snow
+	1213: "snow",          // Light snow
+	1216: "snow",          // Patchy moderate snow
+	1219: "snow",          // Moderate snow
+	1222: "snow",          // Patchy heavy snow
+	1225: "snow",          // Heavy snow
+	1237: "sleet",         // Ice pellets
+	1240: "rain",          // Light rain shower
+	1243: "rain",          // Moderate or heavy rain shower
+	1246: "rain",          // Torrential rain shower
+	1249: "sleet",         // Light sleet showers
+	1252: "sleet",         // Moderate or heavy sleet showers
+	1255: "snow",          // Light snow showers
+	1258: "snow",          // Moderate or heavy snow showers
+	1261: "sleet",         // Light showers of ice pellets
+	1264: "sleet",         // Moderate or heavy showers of ice pellets
+	1273: "thunder",       // Patchy light rain with thunder
+	1276: "thunder",       // Moderate or heavy rain with thunder
+	1279: "thunder",       // Patchy light snow with thunder
+	1282: "thunder",       // Moderate or heavy snow with thunder
+}
+
+// defaultConditionIcon is shown for condition codes missing from conditionIcons.
+const defaultConditionIcon = "cloudy"
+
+// conditionIconName returns the file name of the icon for a WeatherAPI condition
+// code, picking the night variant of the icons that have one when isDay is not set.
+func conditionIconName(code int, isDay bool) string {
+	name, ok := conditionIcons[code]
+	if !ok {
+		name = defaultConditionIcon
+	}
+	switch name {
+	case "clear", "partly-cloudy":
+		if isDay {
+			name += "-day"
+		} else {
+			name += "-night"
+		}
+	}
+	return name + ".svg"
+}
+
+// conditionIcon returns the icon for a WeatherAPI condition code, by day or by
+// night, or nil when it is not embedded, which leaves the image empty.
+func conditionIcon(code int, isDay bool) fyne.Resource {
+	name := conditionIconName(code, isDay)
+	contents, err := iconFiles.ReadFile("icons/" + name)
+	if err != nil {
+		fmt.Println("Error loading condition icon:", err)
+		return nil
+	}
+	return fyne.NewStaticResource(name, contents)
+}
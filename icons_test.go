@@ -0,0 +1,31 @@
+// test file that tests the code in the icons.go file
+package main
+
+import (
+	"testing"
+)
+
+// TestConditionIcon tests that every WeatherAPI condition code has an embedded
+// icon, by day and by night, and that clear skies look different at night
+// tested features - conditionIcon, conditionIconName
+func TestConditionIcon(t *testing.T) {
+	for code := range conditionIcons {
+		for _, isDay := range []bool{true, false} {
+			icon := conditionIcon(code, isDay)
+			if icon == nil || len(icon.Content()) == 0 {
+				t.Errorf("code %d, day %v: no icon for %s", code, isDay, conditionIconName(code, isDay))
+			}
+		}
+	}
+
+	if day, night := conditionIconName(1000, true), conditionIconName(1000, false); day != "clear-day.svg" || night != "clear-night.svg" {
+		t.Errorf("clear: got %s by day and %s by night", day, night)
+	}
+	if day, night := conditionIconName(1189, true), conditionIconName(1189, false); day != night {
+		t.Errorf("rain: got %s by day and %s by night, want the same icon", day, night)
+	}
+	// codes added to the WeatherAPI later still get an icon
+	if name := conditionIconName(9999, true); name != defaultConditionIcon+".svg" {
+		t.Errorf("unknown code: got %s, want %s.svg", name, defaultConditionIcon)
+	}
+}
@@ -0,0 +1,169 @@
+// This file contains the client-side limits on the calls made to the WeatherAPI:
+// a token bucket for the request rate, a cap on the requests in flight and the
+// quota tracker (see quota.go), all applied by rateLimitTransport.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// maxLimiterWait is how long a request with a cached response to fall back on
+// waits for the rate limiter before it fails with ErrRateLimited, so that the
+// cached data is shown instead. Requests without one wait as long as their
+// context allows.
+const maxLimiterWait = 2 * time.Second
+
+// tokenBucket allows Rate requests per second on average, with bursts of up to
+// Burst requests.
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64 // Tokens added per second.
+	burst  float64 // Most tokens the bucket holds.
+	tokens float64 // Tokens available at last, negative when reserved ahead.
+	last   time.Time
+	now    func() time.Time
+}
+
+// newTokenBucket returns a full tokenBucket allowing perMinute requests per minute
+// with bursts of burst requests.
+func newTokenBucket(perMinute float64, burst int) *tokenBucket {
+	if burst < 1 {
+		burst = 1
+	}
+	return &tokenBucket{rate: perMinute / 60, burst: float64(burst), tokens: float64(burst), now: time.Now}
+}
+
+// reserve takes a token and returns how long to wait before using it. When that
+// would be longer than maxWait no token is taken and ok is false.
+func (b *tokenBucket) reserve(maxWait time.Duration) (wait time.Duration, ok bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := b.now()
+	if !b.last.IsZero() {
+		b.tokens = math.Min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	}
+	b.last = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return 0, true
+	}
+	if b.rate <= 0 {
+		return 0, false
+	}
+	wait = time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+	if wait > maxWait {
+		return 0, false
+	}
+	b.tokens--
+	return wait, true
+}
+
+// cancel gives back a token taken by reserve that was not used.
+func (b *tokenBucket) cancel() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.tokens = math.Min(b.burst, b.tokens+1)
+}
+
+// rateLimitTransport passes requests on to Base within the client-side limits:
+// the rate allowed by Bucket, at most cap(slots) requests at a time, and the
+// quota tracked by Quota. Requests wait for their turn, and fail with
+// ErrRateLimited or ErrQuotaExceeded without reaching the WeatherAPI when it
+// would not come in time. Requests made with withCacheOnly fail with errCacheMiss
+// right away: a cache in front would have answered them, so none did.
+type rateLimitTransport struct {
+	Base    http.RoundTripper
+	Bucket  *tokenBucket
+	Quota   *quotaTracker // Counts the requests sent, nil to not track them.
+	MaxWait time.Duration // How long requests with a cached fallback wait for Bucket, see maxLimiterWait.
+	slots   chan struct{}
+}
+
+// newRateLimitTransport returns a rateLimitTransport allowing perMinute requests per
+// minute in bursts of burst, with at most maxConcurrent of them at a time.
+func newRateLimitTransport(base http.RoundTripper, perMinute float64, burst, maxConcurrent int, quota *quotaTracker) *rateLimitTransport {
+	if maxConcurrent < 1 {
+		maxConcurrent = 1
+	}
+	return &rateLimitTransport{
+		Base:    base,
+		Bucket:  newTokenBucket(perMinute, burst),
+		Quota:   quota,
+		MaxWait: maxLimiterWait,
+		slots:   make(chan struct{}, maxConcurrent),
+	}
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *rateLimitTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	ctx := request.Context()
+	if cacheOnly(ctx) {
+		if request.Body != nil {
+			request.Body.Close()
+		}
+		return nil, errCacheMiss
+	}
+	// count the call up front, so that requests waiting together cannot go over
+	// the quota, and take it back when it is not sent after all
+	if t.Quota != nil {
+		if err := t.Quota.reserve(); err != nil {
+			return nil, err
+		}
+	}
+	sent := false
+	defer func() {
+		if !sent && t.Quota != nil {
+			t.Quota.release()
+		}
+	}()
+
+	// wait for a token until the request's deadline, or only briefly when the
+	// cache has older data to show instead
+	maxWait := time.Duration(math.MaxInt64)
+	if deadline, ok := ctx.Deadline(); ok {
+		maxWait = time.Until(deadline)
+	}
+	if hasCachedFallback(ctx) {
+		maxWait = min(maxWait, t.MaxWait)
+	}
+	wait, ok := t.Bucket.reserve(maxWait)
+	if !ok {
+		return nil, fmt.Errorf("%w: more than the allowed requests per minute", ErrRateLimited)
+	}
+	if err := sleep(ctx, wait); err != nil {
+		t.Bucket.cancel()
+		return nil, err
+	}
+
+	select {
+	case t.slots <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	defer func() { <-t.slots }()
+
+	sent = true
+	return t.Base.RoundTrip(request)
+}
+
+// sleep waits for d, returning ctx.Err() when ctx is done first.
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,177 @@
+// test file that tests the code in the limiter.go file
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestTokenBucket tests that the bucket allows its burst at once, then refills
+// at its rate, and refuses tokens that would take too long
+// tested features - tokenBucket
+func TestTokenBucket(t *testing.T) {
+	now := time.Now()
+	bucket := newTokenBucket(60, 2) // one token per second
+	bucket.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		if wait, ok := bucket.reserve(0); !ok || wait != 0 {
+			t.Fatalf("burst token %d: wait %v, ok %v", i, wait, ok)
+		}
+	}
+	if _, ok := bucket.reserve(500 * time.Millisecond); ok {
+		t.Errorf("got a token that needs a second to refill within half a second")
+	}
+	if wait, ok := bucket.reserve(2 * time.Second); !ok || wait != time.Second {
+		t.Errorf("got wait %v, ok %v, want 1s", wait, ok)
+	}
+	bucket.cancel()
+
+	now = now.Add(time.Hour)
+	for i := 0; i < 2; i++ {
+		if _, ok := bucket.reserve(0); !ok {
+			t.Errorf("refilled token %d refused", i)
+		}
+	}
+	if _, ok := bucket.reserve(0); ok {
+		t.Errorf("the bucket holds more than its burst")
+	}
+}
+
+// TestRateLimitTransport tests that the transport caps the requests in flight,
+// makes requests over the rate wait for their turn, fails them without sending
+// them when it would not come in time, and that the cache then serves its older
+// responses without a long wait
+// tested features - rateLimitTransport, cachingTransport
+func TestRateLimitTransport(t *testing.T) {
+	fake := newFakeWeatherAPI(t)
+	fake.setDelay(50 * time.Millisecond)
+	provider := fake.provider()
+	limiter := newRateLimitTransport(provider.Client.Transport, 600, 6, 2, nil)
+	provider.Client = &http.Client{Transport: limiter, Timeout: provider.Client.Timeout}
+
+	// three cities, one request each, two at a time: two rounds of 50ms
+	start := time.Now()
+	var wg sync.WaitGroup
+	for _, cityName := range []string{"Tucson", "Kochi", "Tokyo"} {
+		wg.Add(1)
+		go func(cityName string) {
+			defer wg.Done()
+			if _, err := getCityData(context.Background(), provider, cityName); err != nil {
+				t.Errorf("%s: %v", cityName, err)
+			}
+		}(cityName)
+	}
+	wg.Wait()
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Errorf("3 requests took %v, more than 2 were sent at a time", elapsed)
+	}
+
+	// without cached data to show instead, a request waits for the next token
+	fake.setDelay(0)
+	limiter.Bucket.rate, limiter.Bucket.tokens, limiter.Bucket.last = 5, 0, time.Now() // next token in 200ms
+	start = time.Now()
+	if _, err := getCityData(context.Background(), provider, "Kochi"); err != nil {
+		t.Errorf("waiting for a token: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 150*time.Millisecond {
+		t.Errorf("got a token after %v, want about 200ms", elapsed)
+	}
+
+	// empty the bucket, with the next token further away than the request's deadline
+	limiter.Bucket.rate, limiter.Bucket.tokens = 1.0/3600, 0
+	before := fake.requestCount("")
+	if _, err := getCityData(context.Background(), provider, "Tucson"); !errors.Is(err, ErrRateLimited) {
+		t.Errorf("got %v, want ErrRateLimited", err)
+	}
+	if count := fake.requestCount(""); count != before {
+		t.Errorf("rate limited requests were sent: %d", count-before)
+	}
+
+	// with a cache in front, the limited requests get the cached responses, however old
+	cache := newCachingTransport(t.TempDir(), fake.provider().Client.Transport, time.Minute)
+	cached, err := getCityData(context.Background(), WeatherAPIProvider{BaseURL: provider.BaseURL, APIKey: fakeAPIKey, Client: &http.Client{Transport: cache}}, "Tucson")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache.Base = limiter
+	cache.now = func() time.Time { return time.Now().Add(time.Hour) }
+	provider.Client = &http.Client{Transport: cache}
+	data, err := getCityData(context.Background(), provider, "Tucson")
+	if err != nil || data.ForecastErr != nil {
+		t.Fatalf("limited with a cache: %v, forecast %v", err, data.ForecastErr)
+	}
+	if data.TempC0 != cached.TempC0 || !data.Stale || !errors.Is(data.RefreshErr, ErrRateLimited) {
+		t.Errorf("got temperature %v, stale %v, error %v, want the cached %v, stale and rate limited", data.TempC0, data.Stale, data.RefreshErr, cached.TempC0)
+	}
+
+	// with cached data, a request does not wait longer than MaxWait for a token
+	limiter.MaxWait = 50 * time.Millisecond
+	limiter.Bucket.rate, limiter.Bucket.tokens, limiter.Bucket.last = 5, 0, time.Now()
+	before = fake.requestCount("")
+	start = time.Now()
+	if data, err := getCityData(context.Background(), provider, "Tucson"); err != nil || data.TempC0 != cached.TempC0 {
+		t.Fatalf("got %+v, %v, want the cached data", data, err)
+	}
+	if elapsed := time.Since(start); elapsed > 150*time.Millisecond {
+		t.Errorf("waited %v for a token with cached data to show", elapsed)
+	}
+	if count := fake.requestCount(""); count != before {
+		t.Errorf("a request was sent instead of showing the cached data")
+	}
+}
+
+// TestRateLimitCacheOnly tests that a request for cached data alone fails with
+// errCacheMiss when no cache answered it, without being sent or counted
+// tested features - rateLimitTransport, withCacheOnly
+func TestRateLimitCacheOnly(t *testing.T) {
+	fake := newFakeWeatherAPI(t)
+	provider := fake.provider()
+	quota := newQuotaTracker(filepath.Join(t.TempDir(), "usage.json"), 5, 10, 0.8)
+	limiter := newRateLimitTransport(provider.Client.Transport, 60, 1, 1, quota)
+	provider.Client = &http.Client{Transport: limiter, Timeout: provider.Client.Timeout}
+
+	if _, err := getCityData(withCacheOnly(context.Background()), provider, "Tucson"); !errors.Is(err, errCacheMiss) {
+		t.Errorf("cache only without a cache: got %v, want errCacheMiss", err)
+	}
+	if count := fake.requestCount(""); count != 0 {
+		t.Errorf("cache only sent %d requests", count)
+	}
+	if usage := quota.Usage(); usage.DayCount != 0 {
+		t.Errorf("cache only counted %d calls", usage.DayCount)
+	}
+	// the single token of the bucket is still there
+	if _, err := getCityData(context.Background(), provider, "Tucson"); err != nil {
+		t.Errorf("after a cache only request: %v", err)
+	}
+}
+
+// TestRateLimitQuota tests that a request is counted against the quota once it
+// is sent, and not when the rate limit refuses it
+// tested features - rateLimitTransport, quotaTracker
+func TestRateLimitQuota(t *testing.T) {
+	fake := newFakeWeatherAPI(t)
+	provider := fake.provider()
+	quota := newQuotaTracker(filepath.Join(t.TempDir(), "usage.json"), 5, 10, 0.8)
+	limiter := newRateLimitTransport(provider.Client.Transport, 60, 1, 1, quota)
+	provider.Client = &http.Client{Transport: limiter, Timeout: provider.Client.Timeout}
+
+	if _, err := getCityData(context.Background(), provider, "Tucson"); err != nil {
+		t.Fatal(err)
+	}
+	// the bucket is empty now, and its next token comes after the deadline
+	limiter.Bucket.rate = 1.0 / 3600
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if _, err := getCityData(ctx, provider, "Kochi"); !errors.Is(err, ErrRateLimited) {
+		t.Errorf("got %v, want ErrRateLimited", err)
+	}
+	if usage := quota.Usage(); usage.DayCount != 1 || fake.requestCount("") != 1 {
+		t.Errorf("counted %d calls for %d requests sent, want 1", usage.DayCount, fake.requestCount(""))
+	}
+}
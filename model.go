@@ -1,232 +1,234 @@
 // This file contains structs and function that make up the state/model of a system
 // that displays the weather for a list of cities in an application.
-// The weather data is fetched from the WeatherAPI (https://www.weatherapi.com/) using
-// packages like net/http and net/irl and other auxillary packages to help complete tasks.
+// The weather data is fetched through a WeatherProvider; the WeatherAPI
+// (https://www.weatherapi.com/) implementation lives in weatherapi.go.
 
 package main
 
 import (
 	"bufio"
-	"encoding/json"
-	"fmt"
-	"io/ioutil"
-	"net/http"
-	"net/url"
+	"context"
 	"os"
+	"path/filepath"
 	"strings"
+	"sync"
+	"time"
 )
 
 // WeatherData represents weather-related data for a city.
 type WeatherData struct {
-	GoodResponse bool    // Indicates whether the response from the weather API was successful.
-	CityName     string  // The name of the city.
-	TempC0       float64 // Current temperature in Celsius.
-	TempF0       float64 // Current temperature in Fahrenheit.
-	Humidity     float64 // Humidity percentage.
-	WindMPH      float64 // Wind speed in miles per hour.
-	WindKPH      float64 // Wind speed in kilometers per hour.
-	PrecipInches float64 // Precipitation in inches.
-	PrecipMm     float64 // Precipitation in millimeters.
-	Pressure     float64 // Atmospheric pressure in hPa (hectopascals).
-	Uv           float64 // UV index.
-	WindDir      string  // Wind direction.
-	Condition    string  // Weather condition description.
-	TempC1       float64 // Temperature forecast for Day 1 in Celsius.
-	TempF1       float64 // Temperature forecast for Day 1 in Fahrenheit.
-	TempC2       float64 // Temperature forecast for Day 2 in Celsius.
-	TempF2       float64 // Temperature forecast for Day 2 in Fahrenheit.
-	TempC3       float64 // Temperature forecast for Day 3 in Celsius.
-	TempF3       float64 // Temperature forecast for Day 3 in Fahrenheit.
-	Icon0        string  // Weather icon URL for the current weather.
-	Icon1        string  // Weather icon URL for Day 1 forecast.
-	Icon2        string  // Weather icon URL for Day 2 forecast.
-	Icon3        string  // Weather icon URL for Day 3 forecast.
+	CityName      string           // The name of the city.
+	TempC0        float64          // Current temperature in Celsius.
+	TempF0        float64          // Current temperature in Fahrenheit.
+	Humidity      float64          // Humidity percentage.
+	WindMPH       float64          // Wind speed in miles per hour.
+	WindKPH       float64          // Wind speed in kilometers per hour.
+	PrecipInches  float64          // Precipitation in inches.
+	PrecipMm      float64          // Precipitation in millimeters.
+	Pressure      float64          // Atmospheric pressure in hPa (hectopascals).
+	Uv            float64          // UV index.
+	WindDir       string           // Wind direction.
+	Condition     string           // Weather condition description.
+	ConditionCode int              // WeatherAPI condition code of the current weather, e.g. 1000 for sunny or clear.
+	IsDay         bool             // Set when the sun is up in the city.
+	Icon0         string           // Weather icon URL for the current weather.
+	Forecast      []DailyForecast  // Forecast for the coming days, starting with today.
+	Hourly        []HourlyForecast // Forecast per hour, starting with the current hour.
+	ForecastErr   error            // Why the forecast could not be fetched, nil when Forecast is valid.
+	UpdatedAt     time.Time        // When the WeatherAPI last updated the current conditions.
+	Stale         bool             // Set when this is an older reading: the latest refresh failed, or it was cached long ago.
+	RefreshErr    error            // Why the latest refresh failed, nil when it did not.
+}
+
+// DailyForecast represents the forecast for one day.
+type DailyForecast struct {
+	Date              time.Time // The day forecast, at midnight UTC.
+	MaxTempC          float64   // Highest temperature in Celsius.
+	MaxTempF          float64   // Highest temperature in Fahrenheit.
+	MinTempC          float64   // Lowest temperature in Celsius.
+	MinTempF          float64   // Lowest temperature in Fahrenheit.
+	AvgTempC          float64   // Average temperature in Celsius.
+	AvgTempF          float64   // Average temperature in Fahrenheit.
+	Condition         string    // Weather condition description.
+	ConditionCode     int       // WeatherAPI condition code, e.g. 1000 for sunny or clear.
+	Icon              string    // Weather icon URL.
+	ChanceOfRain      float64   // Chance of rain in percent.
+	ChanceOfSnow      float64   // Chance of snow in percent.
+	TotalPrecipMm     float64   // Total precipitation in millimeters.
+	TotalPrecipInches float64   // Total precipitation in inches.
+	MaxWindKPH        float64   // Highest wind speed in kilometers per hour.
+	MaxWindMPH        float64   // Highest wind speed in miles per hour.
+	Uv                float64   // UV index.
+}
+
+// HourlyForecast represents the forecast for one hour.
+type HourlyForecast struct {
+	Time          time.Time // Start of the hour, in the city's time zone.
+	TempC         float64   // Temperature in Celsius.
+	TempF         float64   // Temperature in Fahrenheit.
+	FeelsLikeC    float64   // Temperature it feels like in Celsius.
+	FeelsLikeF    float64   // Temperature it feels like in Fahrenheit.
+	ChanceOfRain  float64   // Chance of rain in percent.
+	ChanceOfSnow  float64   // Chance of snow in percent.
+	WindKPH       float64   // Wind speed in kilometers per hour.
+	WindMPH       float64   // Wind speed in miles per hour.
+	WindDir       string    // Wind direction.
+	Condition     string    // Weather condition description.
+	ConditionCode int       // WeatherAPI condition code, e.g. 1000 for sunny or clear.
+	Icon          string    // Weather icon URL.
+	IsDay         bool      // Set when the sun is up.
 }
 
 //represents the current state of the program during application run
 type CurrentState struct {
 	CityNames      []string               // List of city names as strings
 	WeatherDataMap map[string]WeatherData // Map of city names to WeatherData structs
+	DefaultCity    string                 // City shown at startup, empty for the first one in CityNames
 }
 
-// getCityData retrieves weather data for a specified city.
-// It takes the cityName as a parameter and returns a WeatherData struct.
-func getCityData(cityName string) WeatherData {
-	var apiKey = "740d078b218647dd88412232230710"
-	// Define the API endpoint URL
-
-	encoded := url.QueryEscape(cityName)
-	apiUrl := "http://api.weatherapi.com/v1/current.json?key=" + apiKey + "&q=" + encoded
-	collectedData := WeatherData{GoodResponse: false}
+// defaultCityMarker starts the line of the default city in cityNames.txt.
+const defaultCityMarker = "*"
 
-	// Send an HTTP GET request to the API
-	response, err := http.Get(apiUrl)
-	if err != nil {
-		fmt.Println("Error:", err)
-		return collectedData
-	}
-	defer response.Body.Close()
-
-	// Read the response body
-	responseBody, err := ioutil.ReadAll(response.Body)
-	if err != nil {
-		fmt.Println("Error reading response body:", err)
-		return collectedData
-	}
-
-	// Check the response status code
-	if response.StatusCode != http.StatusOK {
-		fmt.Println("API request failed with status code:", response.StatusCode)
-		fmt.Println("Response body:", string(responseBody))
-		return collectedData
+// startCity returns the city to show first: the default city while it is
+// tracked, else the first one, or "" when there are no cities.
+func (s CurrentState) startCity() string {
+	for _, cityName := range s.CityNames {
+		if cityName == s.DefaultCity {
+			return cityName
+		}
 	}
-
-	// Parse the JSON response
-	var data map[string]interface{}
-	if err := json.Unmarshal(responseBody, &data); err != nil {
-		fmt.Println("Error parsing JSON response:", err)
-		return collectedData
+	if len(s.CityNames) == 0 {
+		return ""
 	}
-
-	//getting the values from the map returned by the api call
-	tempC := data["current"].(map[string]interface{})["temp_c"].(float64)
-	tempF := data["current"].(map[string]interface{})["temp_f"].(float64)
-	humidity := data["current"].(map[string]interface{})["humidity"].(float64)
-	windMPH := data["current"].(map[string]interface{})["wind_mph"].(float64)
-	windKPH := data["current"].(map[string]interface{})["wind_kph"].(float64)
-	windDir := data["current"].(map[string]interface{})["wind_dir"].(string)
-	precipInches := data["current"].(map[string]interface{})["precip_in"].(float64)
-	precipMm := data["current"].(map[string]interface{})["precip_mm"].(float64)
-	uv := data["current"].(map[string]interface{})["uv"].(float64)
-	condition := data["current"].(map[string]interface{})["condition"].(map[string]interface{})["text"].(string)
-	icon0 := data["current"].(map[string]interface{})["condition"].(map[string]interface{})["icon"].(string)
-	pressure := data["current"].(map[string]interface{})["pressure_mb"].(float64)
-
-	//assigning values to struct
-	collectedData.CityName = cityName
-	collectedData.TempC0 = tempC
-	collectedData.TempF0 = tempF
-	collectedData.Humidity = humidity
-	collectedData.WindMPH = windMPH
-	collectedData.WindKPH = windKPH
-	collectedData.PrecipInches = precipInches
-	collectedData.PrecipMm = precipMm
-	collectedData.Uv = uv
-	collectedData.Condition = condition
-	collectedData.WindDir = windDir
-	collectedData.Icon0 = getImageString(icon0)
-	collectedData.Pressure = pressure
-	collectedData.GoodResponse = true
-
-	dataChannel := make(chan WeatherData)
-	// Launch a Goroutine to fetch and process the data
-	go func() {
-		getWeatherForecast(apiKey, encoded, &collectedData)
-		dataChannel <- collectedData // Send the data to the channel
-	}()
-	// Receive the data from the channel
-	collectedData = <-dataChannel
-
-	return collectedData
-
+	return s.CityNames[0]
 }
 
-// Function to fetch weather forecast for the next three days
-func getWeatherForecast(apiKey, encoded string, collectedData *WeatherData) {
-	//initialize vars
-	apiUrl := "http://api.weatherapi.com/v1/forecast.json?key=" + apiKey + "&q=" + encoded + "&days=3"
-	collectedData.GoodResponse = false
-
-	// Send an HTTP GET request to the API
-	response, err := http.Get(apiUrl)
-	if err != nil {
-		fmt.Println("Error:", err)
-	}
-	defer response.Body.Close()
+// WeatherProvider is a source of weather data for a city. WeatherAPIProvider
+// is the default implementation; other backends or fakes used in tests can be
+// plugged in by implementing this interface.
+type WeatherProvider interface {
+	// Weather returns the current conditions and the forecast for cityName.
+	// When only the forecast is missing, the current conditions are returned
+	// with ForecastErr set instead of an error.
+	Weather(ctx context.Context, cityName string) (WeatherData, error)
+}
 
-	// Read the response body
-	responseBody, err := ioutil.ReadAll(response.Body)
+// getCityData retrieves weather data for a specified city from provider.
+// It takes the cityName as a parameter and returns a WeatherData struct, or an
+// error from errors.go when the current conditions could not be fetched.
+// A failed forecast does not fail the call; it is recorded in ForecastErr and
+// the current conditions are still returned. The request is abandoned when ctx is done.
+func getCityData(ctx context.Context, provider WeatherProvider, cityName string) (WeatherData, error) {
+	collectedData, err := provider.Weather(ctx, cityName)
 	if err != nil {
-		fmt.Println("Error reading response body:", err)
+		return WeatherData{CityName: cityName}, err
 	}
+	return collectedData, nil
+}
 
-	// Check the response status code
-	if response.StatusCode != http.StatusOK {
-		fmt.Println("API request failed with status code:", response.StatusCode)
-		fmt.Println("Response body:", string(responseBody))
+// nextHours returns the hourly forecasts of w from the hour in progress at now
+// on, at most n of them.
+func (w WeatherData) nextHours(now time.Time, n int) []HourlyForecast {
+	for i, hour := range w.Hourly {
+		if hour.Time.Add(time.Hour).After(now) {
+			hours := w.Hourly[i:]
+			if len(hours) > n {
+				hours = hours[:n]
+			}
+			return hours
+		}
 	}
+	return nil
+}
 
-	// Parse the JSON response
-	var data map[string]interface{}
-	if err := json.Unmarshal(responseBody, &data); err != nil {
-		fmt.Println("Error parsing JSON response:", err)
+// keepLastGood returns the data to keep for a city after a refresh: fresh when the
+// refresh succeeded, otherwise the previous reading marked stale, so that a failed
+// refresh never replaces good data. A city without a previous reading stays
+// without data, with only RefreshErr set.
+func keepLastGood(previous, fresh WeatherData, err error) WeatherData {
+	if err == nil {
+		return fresh
 	}
-
-	// Extract temperature data for the next three days if available
-	forecastData, ok := data["forecast"].(map[string]interface{})
-	if !ok {
-		fmt.Println("No forecast data available.")
+	if previous.CityName == "" {
+		return WeatherData{RefreshErr: err}
 	}
+	previous.Stale = true
+	previous.RefreshErr = err
+	return previous
+}
 
-	//collect the required data and set the required fields
-	for i := 0; i < 3; i++ {
-		dayData, ok := forecastData["forecastday"].([]interface{})[i].(map[string]interface{})["day"].(map[string]interface{})
-		if !ok {
-			fmt.Println("No data available for day", i+1)
-			continue
-		}
-
-		avgTempC := dayData["avgtemp_c"].(float64)
-		avgTempF := dayData["avgtemp_f"].(float64)
-		collectedData.SetTemperature((i + 1), avgTempC, avgTempF)
+// updateLag is how old the current conditions may already be when they are
+// fetched, as the WeatherAPI only updates them every 15 minutes.
+const updateLag = 15 * time.Minute
 
-		iconText := dayData["condition"].(map[string]interface{})["icon"].(string)
-		iconText = getImageString(iconText)
-		collectedData.SetIcon((i + 1), iconText)
+// markOld marks data as stale when it was fetched more than maxAge before now,
+// e.g. data read from the cache at startup. The time it was fetched is not kept,
+// so this is when the WeatherAPI updated it more than maxAge plus updateLag ago.
+func markOld(data WeatherData, maxAge time.Duration, now time.Time) WeatherData {
+	if !data.UpdatedAt.IsZero() && now.Sub(data.UpdatedAt) > maxAge+updateLag {
+		data.Stale = true
 	}
+	return data
+}
 
-	collectedData.GoodResponse = true
+// cityResult is the outcome of fetching the weather data of one city,
+// used to pass results back from the goroutines that fetch them.
+type cityResult struct {
+	Data WeatherData // The fetched data, only CityName is set when Err is non-nil.
+	Err  error       // The error returned by getCityData.
 }
 
-//helper funciton used to set the attribute fields of the WeatherData object
-func (w *WeatherData) SetTemperature(day int, tempC float64, tempF float64) {
-	switch day {
-	case 1:
-		w.TempC1 = tempC
-		w.TempF1 = tempF
-	case 2:
-		w.TempC2 = tempC
-		w.TempF2 = tempF
-	case 3:
-		w.TempC3 = tempC
-		w.TempF3 = tempF
+// getAllCityData fetches the weather data for every city in cityNames concurrently.
+// Cities that fail are left out of the returned map and reported in the error map;
+// cities still loading when ctx is done are left out of both.
+func getAllCityData(ctx context.Context, provider WeatherProvider, cityNames []string) (map[string]WeatherData, map[string]error) {
+	dataChannel := make(chan cityResult, len(cityNames)) // Create a buffered channel
+
+	// Launch Goroutines to fetch and process data for each city
+	for _, cityName := range cityNames {
+		go func(cityName string) {
+			cityData, err := getCityData(ctx, provider, cityName)
+			dataChannel <- cityResult{cityData, err} // Send the result to the channel
+		}(cityName)
 	}
-}
 
-//helper funciton used to set the attribute fields of the WeatherData object
-func (w *WeatherData) SetIcon(day int, icon string) {
-	switch day {
-	case 1:
-		w.Icon1 = icon
-	case 2:
-		w.Icon2 = icon
-	case 3:
-		w.Icon3 = icon
+	// Receive the data from the channel for each city until ctx is done
+	weatherDataMap := make(map[string]WeatherData)
+	errs := make(map[string]error)
+	for range cityNames {
+		select {
+		case result := <-dataChannel:
+			if result.Err != nil {
+				errs[result.Data.CityName] = result.Err
+				continue
+			}
+			// Add cityData to the map with cityName as the key
+			weatherDataMap[result.Data.CityName] = result.Data
+		case <-ctx.Done():
+			return weatherDataMap, errs
+		}
 	}
+	return weatherDataMap, errs
 }
 
 //helper function used to help collect the relevant string from the api call
 func getImageString(path string) string {
 	parts := strings.Split(path, "/")
+	if len(parts) < 2 {
+		return path
+	}
 	lastPart := strings.Join(parts[len(parts)-2:], "/")
 	return lastPart
 }
 
-//function used to load data on application startup
-func loadCityNamesFromFile(currentState *CurrentState) error {
+//function used to load data on application startup from the file at filePath
+//a missing file is the first run, which starts without cities
+func loadCityNamesFromFile(currentState *CurrentState, filePath string) error {
 	// Open the file
-	filePath := "cityNames.txt"
 	file, err := os.Open(filePath)
+	if os.IsNotExist(err) {
+		return nil
+	}
 	if err != nil {
 		return err
 	}
@@ -235,9 +237,17 @@ func loadCityNamesFromFile(currentState *CurrentState) error {
 	// Create a scanner to read the file line by line
 	scanner := bufio.NewScanner(file)
 
-	// Read each line and add it to the CityNames slice
+	// Read each line and add it to the CityNames slice, the default city is marked
 	for scanner.Scan() {
 		cityName := scanner.Text()
+		//skip blank lines, e.g. in an emptied file
+		if strings.TrimSpace(cityName) == "" {
+			continue
+		}
+		if strings.HasPrefix(cityName, defaultCityMarker) {
+			cityName = strings.TrimPrefix(cityName, defaultCityMarker)
+			currentState.DefaultCity = cityName
+		}
 		currentState.CityNames = append(currentState.CityNames, cityName)
 	}
 	//return if errored out
@@ -248,21 +258,44 @@ func loadCityNamesFromFile(currentState *CurrentState) error {
 	return nil
 }
 
-//function used to save data on application exit
-func writeCityNamesToFile(currentState CurrentState) error {
-	filePath := "cityNames.txt"
-	// Open the file for writing
-	file, err := os.Create(filePath)
+//cityNamesMu serializes writeCityNamesToFile, e.g. for two cities added at once
+var cityNamesMu sync.Mutex
+
+//function used to save data to the file at filePath
+//the list is written to a temporary file that replaces the old one once it is
+//complete, so a crash or a full disk never leaves a truncated list; the old one
+//is kept as filePath.bak
+func writeCityNamesToFile(currentState CurrentState, filePath string) (err error) {
+	cityNamesMu.Lock()
+	defer cityNamesMu.Unlock()
+
+	//the config directory does not exist before the first city is saved
+	if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+		return err
+	}
+	// Open a temporary file next to the list for writing
+	file, err := os.CreateTemp(filepath.Dir(filePath), filepath.Base(filePath)+".*.tmp")
 	if err != nil {
 		return err
 	}
-	defer file.Close()
+	defer func() {
+		if err != nil {
+			file.Close()
+			os.Remove(file.Name())
+		}
+	}()
+	if err := file.Chmod(0644); err != nil {
+		return err
+	}
 
 	// Create a writer to write to the file
 	writer := bufio.NewWriter(file)
 
 	// Write each city name to the file except for the last one
 	for i, cityName := range currentState.CityNames {
+		if cityName == currentState.DefaultCity {
+			cityName = defaultCityMarker + cityName
+		}
 		_, err := writer.WriteString(cityName)
 		if err != nil {
 			return err
@@ -277,10 +310,64 @@ func writeCityNamesToFile(currentState CurrentState) error {
 		}
 	}
 
-	// Flush any buffered data to the file
+	// Flush any buffered data to the file, and the file to the disk before it
+	// replaces the old list
 	if err := writer.Flush(); err != nil {
 		return err
 	}
+	if err := file.Sync(); err != nil {
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
 
+	// keep the old list, then swap in the new one
+	if err := backupFile(filePath, filePath+".bak"); err != nil {
+		return err
+	}
+	if err := os.Rename(file.Name(), filePath); err != nil {
+		return err
+	}
+	//sync the directory too so that the rename survives a crash, where the OS allows it
+	if dir, err := os.Open(filepath.Dir(filePath)); err == nil {
+		dir.Sync()
+		dir.Close()
+	}
 	return nil
 }
+
+//backupFile copies the file at path to backupPath, if there is one
+func backupFile(path, backupPath string) error {
+	contents, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(backupPath, contents, 0644)
+}
+
+//migrateCityNames copies the city list at oldPath, where it was kept before it
+//moved to the user config directory, to newPath unless there is a list there
+//already. It returns whether it copied anything; the old file is left alone.
+func migrateCityNames(oldPath, newPath string) (bool, error) {
+	if _, err := os.Stat(newPath); err == nil || !os.IsNotExist(err) {
+		return false, err
+	}
+	contents, err := os.ReadFile(oldPath)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if err := os.MkdirAll(filepath.Dir(newPath), 0755); err != nil {
+		return false, err
+	}
+	if err := os.WriteFile(newPath, contents, 0644); err != nil {
+		return false, err
+	}
+	return true, nil
+}
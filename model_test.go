@@ -4,16 +4,47 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
 	"reflect"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
+// testCityNamesPath is where TestWrite saves the city list that TestRead loads,
+// in a temporary directory so that the tests never touch a real cityNames.txt
+var testCityNamesPath string
+
+// TestMain creates the temporary directory of testCityNamesPath
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "weather-app-test")
+	if err != nil {
+		fmt.Println("Error creating the test directory:", err)
+		os.Exit(1)
+	}
+	testCityNamesPath = filepath.Join(dir, cityNamesFileName)
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// testWeatherAPIProvider returns a provider for a fake WeatherAPI serving the
+// recorded responses in testdata/weatherapi, see fakeweatherapi_test.go
+func testWeatherAPIProvider(t *testing.T) WeatherAPIProvider {
+	return newFakeWeatherAPI(t).provider()
+}
+
 //TestState tests the state struct used, whether it is correctly setup and
 //if it correctly retrieves the relevant data.
 //getCityData is tested here as well (getWeatherForecast is include in getCityData)
 //tested features - WeatherData struct, CurrentState struct, getCityData and related functions
 func TestState(t *testing.T) {
+	provider := testWeatherAPIProvider(t)
 	currentState := CurrentState{
 		CityNames:      []string{},
 		WeatherDataMap: make(map[string]WeatherData),
@@ -24,34 +55,32 @@ func TestState(t *testing.T) {
 	currentState.CityNames = append(currentState.CityNames, "Kochi")
 
 	// Create a buffered channel
-	dataChannel := make(chan WeatherData, len(currentState.CityNames))
+	dataChannel := make(chan cityResult, len(currentState.CityNames))
 
 	// Launch Goroutines to fetch and process data for each city
 	for _, cityName := range currentState.CityNames {
 		go func(cityName string) {
-			cityData := getCityData(cityName)
-			dataChannel <- cityData // Send the data to the channel
+			cityData, err := getCityData(context.Background(), provider, cityName)
+			dataChannel <- cityResult{cityData, err} // Send the result to the channel
 		}(cityName)
 	}
 
 	// Receive the data from the channel for each city
 	for range currentState.CityNames {
-		cityData := <-dataChannel
+		result := <-dataChannel
+		// Check if any of the API calls failed
+		if result.Err != nil {
+			t.Errorf("API call failed, No data available for city: %s: %v", result.Data.CityName, result.Err)
+			continue
+		}
 		// Add cityData to the WeatherDataMap with cityName as the key
-		currentState.WeatherDataMap[cityData.CityName] = cityData
+		currentState.WeatherDataMap[result.Data.CityName] = result.Data
 	}
 	// Check if CityNames list is empty
 	if len(currentState.CityNames) == 0 {
 		t.Errorf("CityNames list is empty")
 	}
 
-	// Check if any of the weather structs have no data (GoodResponse is false)
-	for cityName, weatherData := range currentState.WeatherDataMap {
-		if !weatherData.GoodResponse {
-			t.Errorf("Bad Response, API call failed, No data available for city: %s", cityName)
-		}
-	}
-
 	// Check if any of the weather structs have no data (uv will be zero
 	//which is impossible because uv indices start at 1)
 	for cityName, weatherData := range currentState.WeatherDataMap {
@@ -68,6 +97,7 @@ func TestState(t *testing.T) {
 //tested features - WeatherData struct, CurrentState struct, getCityData and related functions,
 //                  writeCityNamesToFile
 func TestWrite(t *testing.T) {
+	provider := testWeatherAPIProvider(t)
 	currentState := CurrentState{
 		CityNames:      []string{},
 		WeatherDataMap: make(map[string]WeatherData),
@@ -77,24 +107,24 @@ func TestWrite(t *testing.T) {
 	currentState.CityNames = append(currentState.CityNames, "Tucson")
 	currentState.CityNames = append(currentState.CityNames, "Kochi")
 
-	dataChannel := make(chan WeatherData, len(currentState.CityNames)) // Create a buffered channel
+	dataChannel := make(chan cityResult, len(currentState.CityNames)) // Create a buffered channel
 
 	// Launch Goroutines to fetch and process data for each city
 	for _, cityName := range currentState.CityNames {
 		go func(cityName string) {
-			cityData := getCityData(cityName)
-			dataChannel <- cityData // Send the data to the channel
+			cityData, err := getCityData(context.Background(), provider, cityName)
+			dataChannel <- cityResult{cityData, err} // Send the result to the channel
 		}(cityName)
 	}
 
 	// Receive the data from the channel for each city
 	for range currentState.CityNames {
-		cityData := <-dataChannel
+		result := <-dataChannel
 		// Add cityData to the WeatherDataMap with cityName as the key
-		currentState.WeatherDataMap[cityData.CityName] = cityData
+		currentState.WeatherDataMap[result.Data.CityName] = result.Data
 	}
 
-	if err := writeCityNamesToFile(currentState); err != nil {
+	if err := writeCityNamesToFile(currentState, testCityNamesPath); err != nil {
 		fmt.Println("Error:", err)
 		return
 	}
@@ -106,6 +136,7 @@ func TestWrite(t *testing.T) {
 //tested features - WeatherData struct, CurrentState struct, getCityData and related functions,
 //                  loadCityNamesToFile
 func TestRead(t *testing.T) {
+	provider := testWeatherAPIProvider(t)
 	testState := CurrentState{
 		CityNames:      []string{},
 		WeatherDataMap: make(map[string]WeatherData),
@@ -115,21 +146,21 @@ func TestRead(t *testing.T) {
 	testState.CityNames = append(testState.CityNames, "Tucson")
 	testState.CityNames = append(testState.CityNames, "Kochi")
 
-	readDataChannel := make(chan WeatherData, len(testState.CityNames)) // Create a buffered channel
+	readDataChannel := make(chan cityResult, len(testState.CityNames)) // Create a buffered channel
 
 	// Launch Goroutines to fetch and process data for each city
 	for _, cityName := range testState.CityNames {
 		go func(cityName string) {
-			cityData := getCityData(cityName)
-			readDataChannel <- cityData // Send the data to the channel
+			cityData, err := getCityData(context.Background(), provider, cityName)
+			readDataChannel <- cityResult{cityData, err} // Send the result to the channel
 		}(cityName)
 	}
 
 	// Receive the data from the channel for each city
 	for range testState.CityNames {
-		cityData := <-readDataChannel
+		result := <-readDataChannel
 		// Add cityData to the WeatherDataMap with cityName as the key
-		testState.WeatherDataMap[cityData.CityName] = cityData
+		testState.WeatherDataMap[result.Data.CityName] = result.Data
 	}
 
 	//model that holds the current state of the program -- NOEL
@@ -139,26 +170,26 @@ func TestRead(t *testing.T) {
 	}
 
 	//adding cities from cityNames.txt
-	if err := loadCityNamesFromFile(&currentState); err != nil {
+	if err := loadCityNamesFromFile(&currentState, testCityNamesPath); err != nil {
 		fmt.Println("Error loading city names into file:", err)
 		return
 	}
 
-	readDataChannel = make(chan WeatherData, len(currentState.CityNames)) // Create a buffered channel
+	readDataChannel = make(chan cityResult, len(currentState.CityNames)) // Create a buffered channel
 
 	// Launch Goroutines to fetch and process data for each city
 	for _, cityName := range currentState.CityNames {
 		go func(cityName string) {
-			cityData := getCityData(cityName)
-			readDataChannel <- cityData // Send the data to the channel
+			cityData, err := getCityData(context.Background(), provider, cityName)
+			readDataChannel <- cityResult{cityData, err} // Send the result to the channel
 		}(cityName)
 	}
 
 	// Receive the data from the channel for each city
 	for range currentState.CityNames {
-		cityData := <-readDataChannel
+		result := <-readDataChannel
 		// Add cityData to the WeatherDataMap with cityName as the key
-		currentState.WeatherDataMap[cityData.CityName] = cityData
+		currentState.WeatherDataMap[result.Data.CityName] = result.Data
 	}
 
 	// Compare CityNames from both CurrentState structs
@@ -167,3 +198,378 @@ func TestRead(t *testing.T) {
 	}
 
 }
+
+// fakeProvider is a WeatherProvider that returns canned data without calling any API
+type fakeProvider struct {
+	weather WeatherData
+	err     error
+	calls   int
+}
+
+func (f *fakeProvider) Weather(ctx context.Context, cityName string) (WeatherData, error) {
+	f.calls++
+	data := f.weather
+	data.CityName = cityName
+	return data, f.err
+}
+
+//TestProvider tests that getCityData takes the current conditions and the
+//forecast from whichever WeatherProvider it is given, in a single call
+//tested features - WeatherProvider interface, getCityData
+func TestProvider(t *testing.T) {
+	forecast := []DailyForecast{{AvgTempC: 20, Icon: "day/113.png"}, {AvgTempC: 21}}
+	provider := &fakeProvider{weather: WeatherData{TempC0: 25, Condition: "Sunny", Forecast: forecast}}
+	data, err := getCityData(context.Background(), provider, "Tucson")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if data.CityName != "Tucson" || data.Condition != "Sunny" || data.TempC0 != 25 {
+		t.Errorf("current conditions not taken from provider: %+v", data)
+	}
+	if !reflect.DeepEqual(data.Forecast, forecast) {
+		t.Errorf("forecast not taken from provider: %+v", data)
+	}
+	if provider.calls != 1 {
+		t.Errorf("provider called %d times, want once", provider.calls)
+	}
+
+	//a failure keeps only the city name
+	provider = &fakeProvider{weather: WeatherData{TempC0: 25}, err: &APIError{StatusCode: 400, Code: 1006, Message: "No matching location found."}}
+	data, err = getCityData(context.Background(), provider, "Nowhere")
+	if !errors.Is(err, ErrCityNotFound) {
+		t.Errorf("expected ErrCityNotFound, got %v", err)
+	}
+	if !reflect.DeepEqual(data, WeatherData{CityName: "Nowhere"}) {
+		t.Errorf("expected only the city name to be kept on error, got %+v", data)
+	}
+
+	//a failed forecast keeps the current conditions and the error
+	provider = &fakeProvider{weather: WeatherData{TempC0: 25, ForecastErr: ErrMalformedResponse}}
+	data, err = getCityData(context.Background(), provider, "Tucson")
+	if err != nil {
+		t.Fatalf("a failed forecast should not fail getCityData, got %v", err)
+	}
+	if data.TempC0 != 25 || !errors.Is(data.ForecastErr, ErrMalformedResponse) {
+		t.Errorf("expected current conditions with ForecastErr set, got %+v", data)
+	}
+}
+
+//TestDecodeResponse tests that malformed or partial WeatherAPI payloads are
+//reported as errors instead of causing a panic
+//tested features - forecastResponse, apiForecast, decodeResponse, getImageString
+func TestDecodeResponse(t *testing.T) {
+	good := `{"location":{"name":"Tucson"},"current":{"last_updated_epoch":1697000000,"temp_c":25.0,` +
+		`"condition":{"text":"Sunny","icon":"//cdn.weatherapi.com/weather/64x64/day/113.png","code":1000}}}`
+	var current forecastResponse
+	if err := decodeResponse([]byte(good), &current); err != nil {
+		t.Fatalf("unexpected error decoding a complete payload: %v", err)
+	}
+	if current.Current.TempC != 25 || getImageString(current.Current.Condition.Icon) != "day/113.png" {
+		t.Errorf("payload decoded incorrectly: %+v", current.Current)
+	}
+	//the forecast is checked on its own
+	if err := current.Forecast.validate(); err == nil {
+		t.Errorf("expected an error for a payload without a forecast block")
+	}
+
+	bad := []string{
+		``,
+		`not json`,
+		`{"location":{"name":"Tucson"}}`,
+		`{"location":{"name":"Tucson"},"current":null}`,
+		`{"location":{"name":"Tucson"},"current":{"temp_c":"hot"}}`,
+		`{"location":{"name":"Tucson"},"current":{"temp_c":25.0}}`,
+	}
+	for _, payload := range bad {
+		var data forecastResponse
+		if err := decodeResponse([]byte(payload), &data); err == nil {
+			t.Errorf("expected an error decoding payload %q", payload)
+		}
+	}
+
+	var forecast forecastResponse
+	partial := `{"location":{"name":"Tucson"},"current":{"last_updated_epoch":1697000000,` +
+		`"condition":{"text":"Sunny"}},"forecast":{"forecastday":[{"date":"2023-10-10"}]}}`
+	if err := decodeResponse([]byte(partial), &forecast); err != nil {
+		t.Fatalf("unexpected error decoding a payload with a partial forecast: %v", err)
+	}
+	if err := forecast.Forecast.validate(); err == nil {
+		t.Errorf("expected an error for a forecast day without a day block")
+	}
+
+	if got := getImageString(""); got != "" {
+		t.Errorf("getImageString(\"\") = %q, want empty string", got)
+	}
+}
+
+//TestParseAPIError tests that WeatherAPI error payloads are mapped to the errors in errors.go
+//tested features - parseAPIError, APIError
+func TestParseAPIError(t *testing.T) {
+	tests := []struct {
+		status int
+		body   string
+		want   error
+	}{
+		{400, `{"error":{"code":1006,"message":"No matching location found."}}`, ErrCityNotFound},
+		{401, `{"error":{"code":2006,"message":"API key is invalid."}}`, ErrInvalidAPIKey},
+		{401, `{"error":{"code":1002,"message":"API key not provided."}}`, ErrInvalidAPIKey},
+		{403, `{"error":{"code":2007,"message":"API key has exceeded calls per month quota."}}`, ErrQuotaExceeded},
+		{403, `{"error":{"code":2008,"message":"API key has been disabled."}}`, ErrInvalidAPIKey},
+	}
+	for _, test := range tests {
+		err := parseAPIError(test.status, []byte(test.body))
+		if !errors.Is(err, test.want) {
+			t.Errorf("parseAPIError(%d, %s) = %v, want %v", test.status, test.body, err, test.want)
+		}
+	}
+
+	//a body without an error payload still gives an APIError with the status code
+	var apiErr *APIError
+	if err := parseAPIError(502, []byte("<html>Bad Gateway</html>")); !errors.As(err, &apiErr) || apiErr.StatusCode != 502 {
+		t.Errorf("expected an APIError with status 502, got %v", err)
+	}
+}
+
+// slowProvider is a WeatherProvider that never answers for the city named "Slow"
+type slowProvider struct{}
+
+func (s *slowProvider) Weather(ctx context.Context, cityName string) (WeatherData, error) {
+	if cityName == "Slow" {
+		<-ctx.Done()
+		return WeatherData{CityName: cityName}, ctx.Err()
+	}
+	return WeatherData{CityName: cityName, Condition: "Sunny"}, nil
+}
+
+//TestGetAllCityData tests that fetching all cities gives up on slow cities when
+//the deadline passes and still returns the ones that loaded
+//tested features - getAllCityData, getCityData with a context
+func TestGetAllCityData(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	weatherDataMap, fetchErrors := getAllCityData(ctx, &slowProvider{}, []string{"Tucson", "Slow", "Kochi"})
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("getAllCityData took %v, expected it to stop at the deadline", elapsed)
+	}
+	if len(weatherDataMap) != 2 || weatherDataMap["Tucson"].Condition != "Sunny" || weatherDataMap["Kochi"].Condition != "Sunny" {
+		t.Errorf("expected Tucson and Kochi to load, got %v", weatherDataMap)
+	}
+	if _, ok := weatherDataMap["Slow"]; ok {
+		t.Errorf("expected Slow to be left out")
+	}
+	if err, ok := fetchErrors["Slow"]; ok && !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("unexpected error for Slow: %v", err)
+	}
+}
+
+//TestKeepLastGood tests that a failed refresh keeps the previous reading and marks it stale
+//tested features - keepLastGood
+func TestKeepLastGood(t *testing.T) {
+	previous := WeatherData{CityName: "Tucson", TempC0: 25, Condition: "Sunny", UpdatedAt: time.Unix(1697000000, 0)}
+	fresh := WeatherData{CityName: "Tucson", TempC0: 27, Condition: "Sunny", UpdatedAt: time.Unix(1697000900, 0)}
+
+	if got := keepLastGood(previous, fresh, nil); !reflect.DeepEqual(got, fresh) {
+		t.Errorf("successful refresh: got %+v, want %+v", got, fresh)
+	}
+
+	got := keepLastGood(previous, WeatherData{CityName: "Tucson"}, ErrNetwork)
+	if got.TempC0 != 25 || got.UpdatedAt != previous.UpdatedAt {
+		t.Errorf("failed refresh replaced the previous reading: %+v", got)
+	}
+	if !got.Stale || !errors.Is(got.RefreshErr, ErrNetwork) {
+		t.Errorf("failed refresh not marked stale: %+v", got)
+	}
+
+	//a later successful refresh clears the stale flag
+	if got := keepLastGood(got, fresh, nil); got.Stale || got.RefreshErr != nil {
+		t.Errorf("successful refresh still marked stale: %+v", got)
+	}
+
+	//nothing to keep when the city never loaded
+	if got := keepLastGood(WeatherData{}, WeatherData{CityName: "Tucson"}, ErrNetwork); got.CityName != "" || got.RefreshErr == nil {
+		t.Errorf("expected no data but the error for a city that never loaded, got %+v", got)
+	}
+}
+
+//TestMarkOld tests that data is marked stale once it may have been fetched
+//longer ago than the given age
+//tested features - markOld
+func TestMarkOld(t *testing.T) {
+	now := time.Date(2023, 10, 11, 9, 0, 0, 0, time.UTC)
+	if data := markOld(WeatherData{CityName: "Tucson", UpdatedAt: now.Add(-5 * time.Minute)}, 10*time.Minute, now); data.Stale {
+		t.Errorf("data updated 5 minutes ago marked stale")
+	}
+	//the WeatherAPI's own data may be 15 minutes old already when it is fetched
+	if data := markOld(WeatherData{CityName: "Tucson", UpdatedAt: now.Add(-20 * time.Minute)}, 10*time.Minute, now); data.Stale {
+		t.Errorf("data updated 20 minutes ago, possibly fetched just now, marked stale")
+	}
+	if data := markOld(WeatherData{CityName: "Tucson", UpdatedAt: now.Add(-30 * time.Minute)}, 10*time.Minute, now); !data.Stale {
+		t.Errorf("data updated 30 minutes ago not marked stale")
+	}
+	if data := markOld(WeatherData{CityName: "Tucson", UpdatedAt: now.Add(-48 * time.Hour)}, 10*time.Minute, now); !data.Stale || data.RefreshErr != nil {
+		t.Errorf("data updated two days ago: got %+v, want stale without an error", data)
+	}
+	if data := markOld(WeatherData{}, 10*time.Minute, now); data.Stale {
+		t.Errorf("a city without data marked stale")
+	}
+}
+
+//TestNextHours tests that the hourly forecast shown starts with the hour in progress
+//tested features - WeatherData.nextHours
+func TestNextHours(t *testing.T) {
+	start := time.Date(2023, 10, 9, 8, 0, 0, 0, time.UTC)
+	var data WeatherData
+	for i := 0; i < 48; i++ {
+		data.Hourly = append(data.Hourly, HourlyForecast{Time: start.Add(time.Duration(i) * time.Hour), TempC: float64(i)})
+	}
+
+	hours := data.nextHours(start.Add(90*time.Minute), 24)
+	if len(hours) != 24 || hours[0].TempC != 1 {
+		t.Errorf("got %d hours starting with %v, want 24 starting with the 09:00 hour", len(hours), hours[0].Time)
+	}
+	if hours := data.nextHours(start.Add(46*time.Hour), 24); len(hours) != 2 {
+		t.Errorf("got %d hours near the end of the forecast, want 2", len(hours))
+	}
+	if hours := data.nextHours(start.Add(48*time.Hour), 24); len(hours) != 0 {
+		t.Errorf("got %d hours after the forecast ended, want none", len(hours))
+	}
+}
+
+//TestFirstRun tests that a missing or blank cityNames.txt starts without cities,
+//and that the first city saved creates the file and the config directory
+//tested features - loadCityNamesFromFile, writeCityNamesToFile
+func TestFirstRun(t *testing.T) {
+	path := filepath.Join(t.TempDir(), appName, cityNamesFileName)
+
+	var state CurrentState
+	if err := loadCityNamesFromFile(&state, path); err != nil || len(state.CityNames) != 0 {
+		t.Fatalf("missing file: got %v, error %v, want no cities", state.CityNames, err)
+	}
+
+	state = CurrentState{CityNames: []string{"Tucson"}, DefaultCity: "Tucson"}
+	if err := writeCityNamesToFile(state, path); err != nil {
+		t.Fatalf("saving the first city: %v", err)
+	}
+	var loaded CurrentState
+	if err := loadCityNamesFromFile(&loaded, path); err != nil || !reflect.DeepEqual(loaded.CityNames, state.CityNames) || loaded.DefaultCity != "Tucson" {
+		t.Errorf("got %+v, error %v, want %+v", loaded, err, state)
+	}
+
+	if err := os.WriteFile(path, []byte("\n  \n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	loaded = CurrentState{}
+	if err := loadCityNamesFromFile(&loaded, path); err != nil || len(loaded.CityNames) != 0 {
+		t.Errorf("blank file: got %q, error %v, want no cities", loaded.CityNames, err)
+	}
+}
+
+//TestMigrateCityNames tests that a city list in the working directory is copied
+//to the config directory once, and never over a list already there
+//tested features - migrateCityNames
+func TestMigrateCityNames(t *testing.T) {
+	dir := t.TempDir()
+	oldPath := filepath.Join(dir, cityNamesFileName)
+	newPath := filepath.Join(dir, "config", cityNamesFileName)
+
+	//nothing to migrate
+	if migrated, err := migrateCityNames(oldPath, newPath); migrated || err != nil {
+		t.Fatalf("without an old list got %v, %v", migrated, err)
+	}
+
+	if err := os.WriteFile(oldPath, []byte("Tucson\nKochi"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if migrated, err := migrateCityNames(oldPath, newPath); !migrated || err != nil {
+		t.Fatalf("got %v, %v, want the list migrated", migrated, err)
+	}
+	var state CurrentState
+	if err := loadCityNamesFromFile(&state, newPath); err != nil || !reflect.DeepEqual(state.CityNames, []string{"Tucson", "Kochi"}) {
+		t.Errorf("migrated list: got %v, error %v", state.CityNames, err)
+	}
+
+	//a list saved in the config directory is kept
+	if err := os.WriteFile(oldPath, []byte("Tokyo"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if migrated, err := migrateCityNames(oldPath, newPath); migrated || err != nil {
+		t.Errorf("migrated over an existing list: %v, %v", migrated, err)
+	}
+	if contents, _ := os.ReadFile(newPath); string(contents) != "Tucson\nKochi" {
+		t.Errorf("the existing list was changed to %q", contents)
+	}
+}
+
+//TestWriteCityNamesAtomic tests that saving the city list replaces it whole, keeps
+//the previous list as a backup, leaves no temporary files behind, and that
+//concurrent saves do not mix their lists
+//tested features - writeCityNamesToFile, backupFile
+func TestWriteCityNamesAtomic(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, cityNamesFileName)
+	readFile := func(path string) string {
+		contents, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(contents)
+	}
+	noTempFiles := func() {
+		if matches, _ := filepath.Glob(filepath.Join(dir, "*.tmp")); len(matches) != 0 {
+			t.Errorf("temporary files left behind: %v", matches)
+		}
+	}
+
+	if err := writeCityNamesToFile(CurrentState{CityNames: []string{"Tucson", "Kochi"}}, path); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeCityNamesToFile(CurrentState{CityNames: []string{"Tokyo"}}, path); err != nil {
+		t.Fatal(err)
+	}
+	if got := readFile(path); got != "Tokyo" {
+		t.Errorf("got list %q, want %q", got, "Tokyo")
+	}
+	if got := readFile(path + ".bak"); got != "Tucson\nKochi" {
+		t.Errorf("got backup %q, want the previous list", got)
+	}
+	noTempFiles()
+
+	//two cities added at once, each save writes a whole list
+	lists := []string{}
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		cityNames := []string{fmt.Sprintf("City %d", i), "Tucson", "Kochi"}
+		lists = append(lists, strings.Join(cityNames, "\n"))
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := writeCityNamesToFile(CurrentState{CityNames: cityNames}, path); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	got := readFile(path)
+	found := false
+	for _, list := range lists {
+		found = found || got == list
+	}
+	if !found {
+		t.Errorf("concurrent saves left the list %q", got)
+	}
+	noTempFiles()
+
+	//a save that fails leaves the list alone, here because the backup cannot be written
+	os.Remove(path + ".bak")
+	if err := os.Mkdir(path+".bak", 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeCityNamesToFile(CurrentState{CityNames: []string{"Atlantis"}}, path); err == nil {
+		t.Errorf("expected an error when the backup cannot be written")
+	}
+	if readFile(path) != got {
+		t.Errorf("a failed save changed the list to %q", readFile(path))
+	}
+	noTempFiles()
+}
@@ -0,0 +1,144 @@
+// This file contains the tracker of how many WeatherAPI calls were made today and
+// this month, kept on disk so that the counts survive restarts.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// quotaUsage is the number of calls made in the current day and month, as stored on disk.
+type quotaUsage struct {
+	Day        string `json:"day"`         // Day counted in DayCount, e.g. "2023-10-09".
+	DayCount   int    `json:"day_count"`   // Calls made on Day.
+	Month      string `json:"month"`       // Month counted in MonthCount, e.g. "2023-10".
+	MonthCount int    `json:"month_count"` // Calls made in Month.
+}
+
+// quotaTracker counts the calls made to the WeatherAPI per day and per month
+// and stops them once a quota is used up.
+type quotaTracker struct {
+	Path         string  // File the counts are kept in, empty to only count in memory.
+	DailyQuota   int     // Calls allowed per day, 0 for no limit.
+	MonthlyQuota int     // Calls allowed per month, 0 for no limit.
+	WarnFraction float64 // Fraction of a quota after which Warning reports the usage.
+
+	mu    sync.Mutex
+	usage quotaUsage
+	now   func() time.Time
+}
+
+// newQuotaTracker returns a quotaTracker keeping its counts in path, starting from
+// the counts already stored there.
+func newQuotaTracker(path string, dailyQuota, monthlyQuota int, warnFraction float64) *quotaTracker {
+	tracker := &quotaTracker{
+		Path:         path,
+		DailyQuota:   dailyQuota,
+		MonthlyQuota: monthlyQuota,
+		WarnFraction: warnFraction,
+		now:          time.Now,
+	}
+	if path != "" {
+		contents, err := os.ReadFile(path)
+		if err == nil {
+			err = json.Unmarshal(contents, &tracker.usage)
+		}
+		if err != nil && !os.IsNotExist(err) {
+			fmt.Println("Error reading API usage:", err)
+		}
+	}
+	return tracker
+}
+
+// current returns the usage for today, resetting the counts of a past day or
+// month. It must be called with the lock held.
+func (q *quotaTracker) current() quotaUsage {
+	now := q.now()
+	if day := now.Format("2006-01-02"); q.usage.Day != day {
+		q.usage.Day, q.usage.DayCount = day, 0
+	}
+	if month := now.Format("2006-01"); q.usage.Month != month {
+		q.usage.Month, q.usage.MonthCount = month, 0
+	}
+	return q.usage
+}
+
+// reserve counts one call and saves the counts, or returns ErrQuotaExceeded and
+// counts nothing when the daily or the monthly quota is used up. Checking and
+// counting are one step, so concurrent calls cannot go over a quota together.
+func (q *quotaTracker) reserve() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	usage := q.current()
+	if q.DailyQuota > 0 && usage.DayCount >= q.DailyQuota {
+		return fmt.Errorf("%w: %d of %d calls made today", ErrQuotaExceeded, usage.DayCount, q.DailyQuota)
+	}
+	if q.MonthlyQuota > 0 && usage.MonthCount >= q.MonthlyQuota {
+		return fmt.Errorf("%w: %d of %d calls made this month", ErrQuotaExceeded, usage.MonthCount, q.MonthlyQuota)
+	}
+	q.usage.DayCount++
+	q.usage.MonthCount++
+	q.saveOrLog()
+	return nil
+}
+
+// release takes back a call counted by reserve that was not made after all.
+func (q *quotaTracker) release() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.current()
+	q.usage.DayCount = max(q.usage.DayCount-1, 0)
+	q.usage.MonthCount = max(q.usage.MonthCount-1, 0)
+	q.saveOrLog()
+}
+
+// saveOrLog saves the counts to q.Path, if there is one, logging failures. It
+// must be called with the lock held.
+func (q *quotaTracker) saveOrLog() {
+	if q.Path == "" {
+		return
+	}
+	if err := q.save(); err != nil {
+		fmt.Println("Error saving API usage:", err)
+	}
+}
+
+// save writes the counts to q.Path. It must be called with the lock held.
+func (q *quotaTracker) save() error {
+	contents, err := json.Marshal(q.usage)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(q.Path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(q.Path, contents, 0644)
+}
+
+// Usage returns the calls made today and this month.
+func (q *quotaTracker) Usage() quotaUsage {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.current()
+}
+
+// Warning returns a message for the user once WarnFraction of the daily or the
+// monthly quota is used, and "" before that.
+func (q *quotaTracker) Warning() string {
+	usage := q.Usage()
+	if q.WarnFraction <= 0 {
+		return ""
+	}
+	if q.DailyQuota > 0 && float64(usage.DayCount) >= q.WarnFraction*float64(q.DailyQuota) {
+		return fmt.Sprintf("%d of %d WeatherAPI calls for today used", usage.DayCount, q.DailyQuota)
+	}
+	if q.MonthlyQuota > 0 && float64(usage.MonthCount) >= q.WarnFraction*float64(q.MonthlyQuota) {
+		return fmt.Sprintf("%d of %d WeatherAPI calls for this month used", usage.MonthCount, q.MonthlyQuota)
+	}
+	return ""
+}
@@ -0,0 +1,107 @@
+// test file that tests the code in the quota.go file
+package main
+
+import (
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestQuotaTracker tests that the calls are counted per day and month, kept
+// across restarts, warned about and refused once a quota is used up
+// tested features - quotaTracker
+func TestQuotaTracker(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "usage.json")
+	now := time.Date(2023, 10, 9, 12, 0, 0, 0, time.Local)
+	newTracker := func() *quotaTracker {
+		tracker := newQuotaTracker(path, 5, 10, 0.5)
+		tracker.now = func() time.Time { return now }
+		return tracker
+	}
+
+	tracker := newTracker()
+	for i := 0; i < 2; i++ {
+		if err := tracker.reserve(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if warning := tracker.Warning(); warning != "" {
+		t.Errorf("warning after 2 of 5 calls: %q", warning)
+	}
+
+	// a restart keeps the counts
+	tracker = newTracker()
+	if err := tracker.reserve(); err != nil {
+		t.Fatal(err)
+	}
+	if usage := tracker.Usage(); usage.DayCount != 3 || usage.MonthCount != 3 {
+		t.Errorf("after a restart got %+v, want 3 calls", usage)
+	}
+	if warning := tracker.Warning(); warning != "3 of 5 WeatherAPI calls for today used" {
+		t.Errorf("got warning %q", warning)
+	}
+	for i := 0; i < 2; i++ {
+		if err := tracker.reserve(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tracker.reserve(); !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("daily quota used up: got %v, want ErrQuotaExceeded", err)
+	}
+	if usage := tracker.Usage(); usage.DayCount != 5 {
+		t.Errorf("a refused call was counted: %+v", usage)
+	}
+
+	// a call taken back frees its place again
+	tracker.release()
+	if err := tracker.reserve(); err != nil {
+		t.Errorf("after a release: %v", err)
+	}
+
+	// the next day starts a new daily count, the month goes on
+	now = now.AddDate(0, 0, 1)
+	if warning := tracker.Warning(); warning != "5 of 10 WeatherAPI calls for this month used" {
+		t.Errorf("got warning %q", warning)
+	}
+	for i := 0; i < 5; i++ {
+		if err := tracker.reserve(); err != nil {
+			t.Fatalf("next day: %v", err)
+		}
+	}
+	if err := tracker.reserve(); !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("monthly quota used up: got %v, want ErrQuotaExceeded", err)
+	}
+
+	// and the next month a new monthly count
+	now = now.AddDate(0, 1, 0)
+	if usage := tracker.Usage(); usage.DayCount != 0 || usage.MonthCount != 0 || usage.Month != "2023-11" {
+		t.Errorf("next month got %+v", usage)
+	}
+}
+
+// TestQuotaTrackerConcurrent tests that calls reserved at the same time cannot
+// go over the quota together
+// tested features - quotaTracker.reserve
+func TestQuotaTrackerConcurrent(t *testing.T) {
+	tracker := newQuotaTracker("", 5, 0, 0)
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	reserved := 0
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if tracker.reserve() == nil {
+				mu.Lock()
+				reserved++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if usage := tracker.Usage(); reserved != 5 || usage.DayCount != 5 {
+		t.Errorf("reserved %d calls, counted %d, want 5 of each", reserved, usage.DayCount)
+	}
+}
@@ -0,0 +1,96 @@
+// This file contains the retry logic used for transient WeatherAPI failures:
+// exponential backoff with jitter, and the Retry-After header for 429 responses.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy controls how failed requests to the WeatherAPI are retried.
+// Only GET requests are sent, so every request is safe to repeat.
+type RetryPolicy struct {
+	Attempts  int           // Total number of attempts, values below 2 disable retrying.
+	BaseDelay time.Duration // Delay before the first retry, doubled for every further retry.
+	MaxDelay  time.Duration // Upper bound for the delay between two attempts.
+}
+
+// isTransient reports whether err is worth retrying: network failures, rate
+// limiting, server errors and WeatherAPI's internal application error.
+// Errors such as ErrCityNotFound or ErrInvalidAPIKey are permanent.
+func isTransient(err error) bool {
+	if errors.Is(err, ErrNetwork) {
+		return true
+	}
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode == http.StatusTooManyRequests || apiErr.StatusCode >= 500 || apiErr.Code == 9999
+	}
+	return false
+}
+
+// backoff returns the delay before retry number retry (starting at 1): the base
+// delay doubled for every earlier retry and capped at MaxDelay, of which a random
+// half is taken off so that clients retrying together spread out.
+func (p RetryPolicy) backoff(retry int) time.Duration {
+	delay := p.BaseDelay
+	for i := 1; i < retry && delay < p.MaxDelay; i++ {
+		delay *= 2
+	}
+	if p.MaxDelay > 0 && delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	if delay <= 0 {
+		return 0
+	}
+	half := delay / 2
+	return half + time.Duration(rand.Int63n(int64(delay-half)+1))
+}
+
+// retry calls fn until it succeeds, fails with a permanent error, ctx is done or
+// the attempts run out, and returns the last error. A Retry-After longer than
+// MaxDelay is not waited for; the error is returned instead.
+func (p RetryPolicy) retry(ctx context.Context, fn func() error) error {
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= p.Attempts || !isTransient(err) {
+			return err
+		}
+
+		delay := p.backoff(attempt)
+		var apiErr *APIError
+		if errors.As(err, &apiErr) && apiErr.RetryAfter > 0 {
+			if p.MaxDelay > 0 && apiErr.RetryAfter > p.MaxDelay {
+				return err
+			}
+			delay = apiErr.RetryAfter
+		}
+
+		if err := sleep(ctx, delay); err != nil {
+			return err
+		}
+	}
+}
+
+// parseRetryAfter returns the delay asked for by a Retry-After header, given either
+// in seconds or as an HTTP date, or 0 when the header is missing or invalid.
+func parseRetryAfter(header string, now time.Time) time.Duration {
+	if header == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(header); err == nil {
+		if seconds < 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(header); err == nil && date.After(now) {
+		return date.Sub(now)
+	}
+	return 0
+}
@@ -0,0 +1,108 @@
+// test file that tests the code in the retry.go file
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// TestRetry tests that transient errors are retried and permanent ones fail fast
+// tested features - RetryPolicy, isTransient
+func TestRetry(t *testing.T) {
+	policy := RetryPolicy{Attempts: 3, BaseDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond}
+
+	tests := []struct {
+		name      string
+		err       error
+		wantCalls int
+	}{
+		{"network", ErrNetwork, 3},
+		{"server error", &APIError{StatusCode: 503}, 3},
+		{"internal application error", &APIError{StatusCode: 400, Code: 9999}, 3},
+		{"city not found", &APIError{StatusCode: 400, Code: 1006}, 1},
+		{"invalid key", &APIError{StatusCode: 401, Code: 2006}, 1},
+		{"malformed", ErrMalformedResponse, 1},
+	}
+	for _, test := range tests {
+		calls := 0
+		err := policy.retry(context.Background(), func() error {
+			calls++
+			return test.err
+		})
+		if !errors.Is(err, test.err) {
+			t.Errorf("%s: retry returned %v, want %v", test.name, err, test.err)
+		}
+		if calls != test.wantCalls {
+			t.Errorf("%s: %d calls, want %d", test.name, calls, test.wantCalls)
+		}
+	}
+
+	// a transient error followed by a success
+	calls := 0
+	err := policy.retry(context.Background(), func() error {
+		calls++
+		if calls == 1 {
+			return ErrNetwork
+		}
+		return nil
+	})
+	if err != nil || calls != 2 {
+		t.Errorf("expected success on the second call, got %v after %d calls", err, calls)
+	}
+
+	// a Retry-After longer than MaxDelay is not waited for
+	calls = 0
+	rateLimited := &APIError{StatusCode: http.StatusTooManyRequests, RetryAfter: time.Hour}
+	err = policy.retry(context.Background(), func() error {
+		calls++
+		return rateLimited
+	})
+	if err != rateLimited || calls != 1 {
+		t.Errorf("expected to give up on a long Retry-After, got %v after %d calls", err, calls)
+	}
+
+	// a cancelled context stops the retries
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = RetryPolicy{Attempts: 5, BaseDelay: time.Hour, MaxDelay: time.Hour}.retry(ctx, func() error {
+		return ErrNetwork
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+}
+
+// TestBackoff tests that the delay grows exponentially, stays within MaxDelay and is jittered
+// tested features - RetryPolicy.backoff, parseRetryAfter
+func TestBackoff(t *testing.T) {
+	policy := RetryPolicy{Attempts: 10, BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+	for retry := 1; retry <= 8; retry++ {
+		want := policy.BaseDelay << (retry - 1)
+		if want > policy.MaxDelay {
+			want = policy.MaxDelay
+		}
+		for i := 0; i < 20; i++ {
+			if delay := policy.backoff(retry); delay < want/2 || delay > want {
+				t.Fatalf("backoff(%d) = %v, want between %v and %v", retry, delay, want/2, want)
+			}
+		}
+	}
+
+	now := time.Date(2023, 10, 9, 12, 0, 0, 0, time.UTC)
+	tests := map[string]time.Duration{
+		"":                              0,
+		"30":                            30 * time.Second,
+		"-1":                            0,
+		"soon":                          0,
+		"Mon, 09 Oct 2023 12:01:00 GMT": time.Minute,
+		"Mon, 09 Oct 2023 11:00:00 GMT": 0,
+	}
+	for header, want := range tests {
+		if got := parseRetryAfter(header, now); got != want {
+			t.Errorf("parseRetryAfter(%q) = %v, want %v", header, got, want)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+// This file contains the scheduler that refreshes the weather data of every
+// tracked city in the background.
+
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// RefreshScheduler keeps the weather data of every tracked city current. Each
+// city is refreshed once per Interval, and the refreshes are spread evenly over
+// the interval instead of being sent in a burst.
+type RefreshScheduler struct {
+	Interval time.Duration                              // How often every city is refreshed.
+	Cities   func() []string                            // Returns the tracked cities, called at the start of every round.
+	Refresh  func(ctx context.Context, cityName string) // Refreshes one city.
+
+	mu      sync.Mutex
+	pending []string      // Cities to refresh right away, in order, see RefreshNow.
+	wake    chan struct{} // Signalled when cities are added to pending.
+}
+
+// newRefreshScheduler returns a RefreshScheduler that calls refresh for each of the
+// cities returned by cities once per interval.
+func newRefreshScheduler(interval time.Duration, cities func() []string, refresh func(ctx context.Context, cityName string)) *RefreshScheduler {
+	return &RefreshScheduler{
+		Interval: interval,
+		Cities:   cities,
+		Refresh:  refresh,
+		wake:     make(chan struct{}, 1),
+	}
+}
+
+// Run refreshes the cities until ctx is done. Refreshes never overlap: the
+// on-demand ones from RefreshNow run between the scheduled ones.
+func (s *RefreshScheduler) Run(ctx context.Context) {
+	for {
+		cities := s.Cities()
+		if len(cities) == 0 {
+			if !s.wait(ctx, s.Interval) {
+				return
+			}
+			continue
+		}
+
+		step := s.Interval / time.Duration(len(cities))
+		for _, cityName := range cities {
+			if !s.wait(ctx, step) {
+				return
+			}
+			s.Refresh(ctx, cityName)
+		}
+	}
+}
+
+// RefreshNow asks for the cities to be refreshed right away instead of waiting for
+// their turn. It does not block, and cities that are already waiting for an
+// on-demand refresh are only refreshed once.
+func (s *RefreshScheduler) RefreshNow(cityNames ...string) {
+	s.mu.Lock()
+	for _, cityName := range cityNames {
+		if !contains(s.pending, cityName) {
+			s.pending = append(s.pending, cityName)
+		}
+	}
+	s.mu.Unlock()
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// next removes and returns the first city waiting for an on-demand refresh.
+func (s *RefreshScheduler) next() (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.pending) == 0 {
+		return "", false
+	}
+	cityName := s.pending[0]
+	s.pending = s.pending[1:]
+	return cityName, true
+}
+
+// wait sleeps for d while serving requests from RefreshNow. It returns false when
+// ctx is done.
+func (s *RefreshScheduler) wait(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return false
+		case <-s.wake:
+			for cityName, ok := s.next(); ok && ctx.Err() == nil; cityName, ok = s.next() {
+				s.Refresh(ctx, cityName)
+			}
+		case <-timer.C:
+			return true
+		}
+	}
+}
+
+// contains reports whether cityNames holds cityName.
+func contains(cityNames []string, cityName string) bool {
+	for _, name := range cityNames {
+		if name == cityName {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,92 @@
+// test file that tests the code in the scheduler.go file
+package main
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestRefreshScheduler tests that every city is refreshed, spread over the
+// interval, and that RefreshNow refreshes a city without waiting for its turn
+// tested features - RefreshScheduler
+func TestRefreshScheduler(t *testing.T) {
+	var mu sync.Mutex
+	refreshed := make(map[string][]time.Time)
+	refresh := func(ctx context.Context, cityName string) {
+		mu.Lock()
+		defer mu.Unlock()
+		refreshed[cityName] = append(refreshed[cityName], time.Now())
+	}
+	cities := func() []string { return []string{"Tucson", "Kochi", "Tokyo"} }
+
+	interval := 300 * time.Millisecond
+	scheduler := newRefreshScheduler(interval, cities, refresh)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	start := time.Now()
+	go func() {
+		scheduler.Run(ctx)
+		close(done)
+	}()
+
+	scheduler.RefreshNow("Kochi")
+	time.Sleep(interval + interval/2)
+	cancel()
+	<-done
+
+	mu.Lock()
+	defer mu.Unlock()
+	for _, cityName := range cities() {
+		if len(refreshed[cityName]) == 0 {
+			t.Errorf("%s was never refreshed", cityName)
+		}
+	}
+	// the on-demand refresh of Kochi comes before the first scheduled refresh
+	if len(refreshed["Kochi"]) < 2 || refreshed["Kochi"][0].Sub(start) >= interval/3 {
+		t.Errorf("RefreshNow did not refresh Kochi right away: %v", refreshed["Kochi"])
+	}
+	// the scheduled refreshes are spread out, the first one after a third of the interval
+	if first := refreshed["Tucson"][0].Sub(start); first < interval/3-interval/10 {
+		t.Errorf("first scheduled refresh after %v, expected about %v", first, interval/3)
+	}
+}
+
+// TestRefreshNowQueue tests that RefreshNow queues any number of cities, each
+// once, and refreshes them in order
+// tested features - RefreshScheduler.RefreshNow
+func TestRefreshNowQueue(t *testing.T) {
+	var mu sync.Mutex
+	var refreshed []string
+	refresh := func(ctx context.Context, cityName string) {
+		mu.Lock()
+		defer mu.Unlock()
+		refreshed = append(refreshed, cityName)
+	}
+	cityNames := []string{"A", "B", "C", "D", "E", "F", "G", "H", "I", "J", "K", "L"}
+	scheduler := newRefreshScheduler(time.Hour, func() []string { return nil }, refresh)
+	scheduler.RefreshNow(cityNames...)
+	scheduler.RefreshNow("A", "B")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		scheduler.Run(ctx)
+		close(done)
+	}()
+	time.Sleep(100 * time.Millisecond)
+	cancel()
+	<-done
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(refreshed) != len(cityNames) {
+		t.Fatalf("refreshed %v, want %v", refreshed, cityNames)
+	}
+	for i, cityName := range cityNames {
+		if refreshed[i] != cityName {
+			t.Errorf("refresh %d was %s, want %s", i, refreshed[i], cityName)
+		}
+	}
+}
@@ -0,0 +1,226 @@
+// This file contains the store that holds the CurrentState while the application
+// runs. The refresh scheduler writes to it from its own goroutine and the GUI
+// reads and writes it from Fyne callbacks, so all access goes through its methods.
+
+package main
+
+import (
+	"sync"
+)
+
+// StoreEvent describes a change made to a WeatherStore.
+type StoreEvent struct {
+	CityName      string // City whose weather data changed, empty when only the list changed.
+	CitiesChanged bool   // Set when the list or the default city changed.
+}
+
+// WeatherStore guards a CurrentState for concurrent access. Reads return copies,
+// and subscribers are told about every change.
+type WeatherStore struct {
+	mu          sync.RWMutex
+	state       CurrentState
+	subscribers map[int]func(StoreEvent)
+	nextID      int
+}
+
+// newWeatherStore returns a WeatherStore holding a copy of state.
+func newWeatherStore(state CurrentState) *WeatherStore {
+	return &WeatherStore{
+		state:       copyState(state),
+		subscribers: make(map[int]func(StoreEvent)),
+	}
+}
+
+// copyState returns a deep copy of state, sharing nothing with it.
+func copyState(state CurrentState) CurrentState {
+	copied := CurrentState{
+		CityNames:      append([]string{}, state.CityNames...),
+		WeatherDataMap: make(map[string]WeatherData, len(state.WeatherDataMap)),
+		DefaultCity:    state.DefaultCity,
+	}
+	for cityName, data := range state.WeatherDataMap {
+		copied.WeatherDataMap[cityName] = copyWeather(data)
+	}
+	return copied
+}
+
+// copyWeather returns a copy of data that shares no slices with it.
+func copyWeather(data WeatherData) WeatherData {
+	data.Forecast = append([]DailyForecast(nil), data.Forecast...)
+	data.Hourly = append([]HourlyForecast(nil), data.Hourly...)
+	return data
+}
+
+// Snapshot returns a copy of the whole state that the caller may use freely.
+func (s *WeatherStore) Snapshot() CurrentState {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return copyState(s.state)
+}
+
+// CityNames returns a copy of the list of tracked cities.
+func (s *WeatherStore) CityNames() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]string{}, s.state.CityNames...)
+}
+
+// Weather returns the weather data of cityName, and whether there is any.
+func (s *WeatherStore) Weather(cityName string) (WeatherData, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	data, ok := s.state.WeatherDataMap[cityName]
+	return copyWeather(data), ok
+}
+
+// HasCity reports whether cityName is in the list of tracked cities.
+func (s *WeatherStore) HasCity(cityName string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.hasCity(cityName)
+}
+
+// hasCity is HasCity for callers already holding the lock.
+func (s *WeatherStore) hasCity(cityName string) bool {
+	return s.cityIndex(cityName) >= 0
+}
+
+// cityIndex returns the position of cityName in the list of tracked cities, or
+// -1 when it is not tracked. It must be called with the lock held.
+func (s *WeatherStore) cityIndex(cityName string) int {
+	for i, name := range s.state.CityNames {
+		if name == cityName {
+			return i
+		}
+	}
+	return -1
+}
+
+// DefaultCity returns the city pinned as the default, or "" when none is.
+func (s *WeatherStore) DefaultCity() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.state.DefaultCity
+}
+
+// StartCity returns the city to show first, see CurrentState.startCity.
+func (s *WeatherStore) StartCity() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.state.startCity()
+}
+
+// UpdateWeather replaces the weather data of cityName with update(previous),
+// where previous is the current data, as one atomic step. Cities that are not
+// tracked (for example removed while a refresh was running) are left alone.
+func (s *WeatherStore) UpdateWeather(cityName string, update func(previous WeatherData) WeatherData) {
+	s.mu.Lock()
+	if !s.hasCity(cityName) {
+		s.mu.Unlock()
+		return
+	}
+	s.state.WeatherDataMap[cityName] = update(s.state.WeatherDataMap[cityName])
+	s.mu.Unlock()
+	s.notify(StoreEvent{CityName: cityName})
+}
+
+// AddCity appends cityName with its weather data to the list of tracked cities.
+// It returns false and changes nothing when the city is already tracked.
+func (s *WeatherStore) AddCity(cityName string, data WeatherData) bool {
+	s.mu.Lock()
+	if s.hasCity(cityName) {
+		s.mu.Unlock()
+		return false
+	}
+	s.state.CityNames = append(s.state.CityNames, cityName)
+	s.state.WeatherDataMap[cityName] = data
+	s.mu.Unlock()
+	s.notify(StoreEvent{CityName: cityName, CitiesChanged: true})
+	return true
+}
+
+// RemoveCity removes cityName and its weather data from the tracked cities. It
+// returns false and changes nothing when the city is not tracked.
+func (s *WeatherStore) RemoveCity(cityName string) bool {
+	s.mu.Lock()
+	index := s.cityIndex(cityName)
+	if index < 0 {
+		s.mu.Unlock()
+		return false
+	}
+	s.state.CityNames = append(s.state.CityNames[:index:index], s.state.CityNames[index+1:]...)
+	delete(s.state.WeatherDataMap, cityName)
+	if s.state.DefaultCity == cityName {
+		s.state.DefaultCity = ""
+	}
+	s.mu.Unlock()
+	s.notify(StoreEvent{CityName: cityName, CitiesChanged: true})
+	return true
+}
+
+// MoveCity moves cityName by offset places in the list of tracked cities, up for
+// a negative offset, stopping at either end. It returns false and changes nothing
+// when the city is not tracked or is already at that end.
+func (s *WeatherStore) MoveCity(cityName string, offset int) bool {
+	s.mu.Lock()
+	index := s.cityIndex(cityName)
+	target := min(max(index+offset, 0), len(s.state.CityNames)-1)
+	if index < 0 || target == index {
+		s.mu.Unlock()
+		return false
+	}
+	names := s.state.CityNames
+	if target < index {
+		copy(names[target+1:index+1], names[target:index])
+	} else {
+		copy(names[index:target], names[index+1:target+1])
+	}
+	names[target] = cityName
+	s.mu.Unlock()
+	s.notify(StoreEvent{CitiesChanged: true})
+	return true
+}
+
+// SetDefaultCity pins cityName as the city shown at startup, or unpins the
+// default city for "". It returns false and changes nothing when cityName is
+// not tracked.
+func (s *WeatherStore) SetDefaultCity(cityName string) bool {
+	s.mu.Lock()
+	if cityName != "" && !s.hasCity(cityName) {
+		s.mu.Unlock()
+		return false
+	}
+	s.state.DefaultCity = cityName
+	s.mu.Unlock()
+	s.notify(StoreEvent{CitiesChanged: true})
+	return true
+}
+
+// Subscribe registers fn to be called after every change, and returns a function
+// that removes it again. fn is called on the goroutine that made the change,
+// without the store locked, so it may read from the store.
+func (s *WeatherStore) Subscribe(fn func(StoreEvent)) (unsubscribe func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id := s.nextID
+	s.nextID++
+	s.subscribers[id] = fn
+	return func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		delete(s.subscribers, id)
+	}
+}
+
+// notify calls the subscribers with event.
+func (s *WeatherStore) notify(event StoreEvent) {
+	s.mu.RLock()
+	subscribers := make([]func(StoreEvent), 0, len(s.subscribers))
+	for _, fn := range s.subscribers {
+		subscribers = append(subscribers, fn)
+	}
+	s.mu.RUnlock()
+	for _, fn := range subscribers {
+		fn(event)
+	}
+}
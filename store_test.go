@@ -0,0 +1,225 @@
+// test file that tests the code in the store.go file, run with go test -race
+package main
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+)
+
+// TestWeatherStore tests that the store hands out copies and tells subscribers about changes
+// tested features - WeatherStore
+func TestWeatherStore(t *testing.T) {
+	state := CurrentState{
+		CityNames:      []string{"Tucson"},
+		WeatherDataMap: map[string]WeatherData{"Tucson": {CityName: "Tucson", TempC0: 25}},
+	}
+	store := newWeatherStore(state)
+
+	// changing the original or a snapshot does not change the store
+	state.CityNames[0] = "Changed"
+	snapshot := store.Snapshot()
+	snapshot.WeatherDataMap["Tucson"] = WeatherData{}
+	if names := store.CityNames(); !reflect.DeepEqual(names, []string{"Tucson"}) {
+		t.Errorf("store shares its city list: %v", names)
+	}
+	if data, _ := store.Weather("Tucson"); data.TempC0 != 25 {
+		t.Errorf("store shares its weather data: %+v", data)
+	}
+	// nor does changing the forecast of a city read from it
+	store.UpdateWeather("Tucson", func(previous WeatherData) WeatherData {
+		previous.Forecast = []DailyForecast{{MaxTempC: 30}}
+		return previous
+	})
+	read, _ := store.Weather("Tucson")
+	read.Forecast[0].MaxTempC = 0
+	if data, _ := store.Weather("Tucson"); data.Forecast[0].MaxTempC != 30 {
+		t.Errorf("store shares its forecast: %+v", data.Forecast)
+	}
+
+	var events []StoreEvent
+	unsubscribe := store.Subscribe(func(event StoreEvent) {
+		events = append(events, event)
+	})
+
+	if !store.AddCity("Kochi", WeatherData{CityName: "Kochi"}) {
+		t.Errorf("AddCity refused a new city")
+	}
+	if store.AddCity("Kochi", WeatherData{CityName: "Kochi"}) {
+		t.Errorf("AddCity accepted a city twice")
+	}
+	store.UpdateWeather("Tucson", func(previous WeatherData) WeatherData {
+		previous.TempC0++
+		return previous
+	})
+	// cities that are not tracked are not updated
+	store.UpdateWeather("Tokyo", func(previous WeatherData) WeatherData {
+		return WeatherData{CityName: "Tokyo"}
+	})
+	if _, ok := store.Weather("Tokyo"); ok {
+		t.Errorf("UpdateWeather added an untracked city")
+	}
+	if data, _ := store.Weather("Tucson"); data.TempC0 != 26 {
+		t.Errorf("UpdateWeather did not update Tucson: %+v", data)
+	}
+
+	want := []StoreEvent{{CityName: "Kochi", CitiesChanged: true}, {CityName: "Tucson"}}
+	if !reflect.DeepEqual(events, want) {
+		t.Errorf("events = %+v, want %+v", events, want)
+	}
+
+	unsubscribe()
+	store.AddCity("Tokyo", WeatherData{CityName: "Tokyo"})
+	if len(events) != 2 {
+		t.Errorf("subscriber called after unsubscribing: %+v", events)
+	}
+}
+
+// TestWeatherStoreConcurrent tests that the store can be used from many goroutines
+// at once, which the race detector checks
+// tested features - WeatherStore
+func TestWeatherStoreConcurrent(t *testing.T) {
+	store := newWeatherStore(CurrentState{
+		CityNames:      []string{"Tucson", "Kochi"},
+		WeatherDataMap: make(map[string]WeatherData),
+	})
+	var mu sync.Mutex
+	updates := 0
+	store.Subscribe(func(event StoreEvent) {
+		if _, ok := store.Weather(event.CityName); ok {
+			mu.Lock()
+			updates++
+			mu.Unlock()
+		}
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for _, cityName := range store.CityNames() {
+				store.UpdateWeather(cityName, func(previous WeatherData) WeatherData {
+					previous.CityName = cityName
+					previous.TempC0++
+					return previous
+				})
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			snapshot := store.Snapshot()
+			for range snapshot.WeatherDataMap {
+			}
+		}()
+	}
+	wg.Wait()
+
+	for _, cityName := range []string{"Tucson", "Kochi"} {
+		if data, _ := store.Weather(cityName); data.TempC0 != 10 {
+			t.Errorf("%s updated %v times, want 10", cityName, data.TempC0)
+		}
+	}
+	if updates != 20 {
+		t.Errorf("subscriber saw %d updates, want 20", updates)
+	}
+}
+
+// TestWeatherStoreRemoveCity tests that removing cities drops their weather data,
+// keeps the order of the others, and can leave the store empty
+// tested features - WeatherStore.RemoveCity
+func TestWeatherStoreRemoveCity(t *testing.T) {
+	store := newWeatherStore(CurrentState{
+		CityNames: []string{"Tucson", "Kochi", "Tokyo"},
+		WeatherDataMap: map[string]WeatherData{
+			"Tucson": {CityName: "Tucson"},
+			"Kochi":  {CityName: "Kochi"},
+			"Tokyo":  {CityName: "Tokyo"},
+		},
+	})
+	var events []StoreEvent
+	store.Subscribe(func(event StoreEvent) {
+		events = append(events, event)
+	})
+
+	if !store.RemoveCity("Kochi") {
+		t.Fatalf("RemoveCity refused a tracked city")
+	}
+	if store.RemoveCity("Kochi") {
+		t.Errorf("RemoveCity removed a city twice")
+	}
+	if names := store.CityNames(); !reflect.DeepEqual(names, []string{"Tucson", "Tokyo"}) {
+		t.Errorf("after removing Kochi got %v", names)
+	}
+	if _, ok := store.Weather("Kochi"); ok {
+		t.Errorf("the weather data of a removed city is kept")
+	}
+	// a refresh finishing after the removal does not bring the city back
+	store.UpdateWeather("Kochi", func(previous WeatherData) WeatherData {
+		return WeatherData{CityName: "Kochi"}
+	})
+	if store.HasCity("Kochi") {
+		t.Errorf("a refresh brought back a removed city")
+	}
+
+	store.RemoveCity("Tucson")
+	store.RemoveCity("Tokyo")
+	if snapshot := store.Snapshot(); len(snapshot.CityNames) != 0 || len(snapshot.WeatherDataMap) != 0 {
+		t.Errorf("after removing every city got %+v", snapshot)
+	}
+	want := []StoreEvent{{CityName: "Kochi", CitiesChanged: true}, {CityName: "Tucson", CitiesChanged: true}, {CityName: "Tokyo", CitiesChanged: true}}
+	if !reflect.DeepEqual(events, want) {
+		t.Errorf("events = %+v, want %+v", events, want)
+	}
+}
+
+// TestWeatherStoreOrder tests moving cities up and down the list and pinning the
+// default city shown at startup
+// tested features - WeatherStore.MoveCity, WeatherStore.SetDefaultCity, WeatherStore.StartCity
+func TestWeatherStoreOrder(t *testing.T) {
+	store := newWeatherStore(CurrentState{
+		CityNames:      []string{"Tucson", "Kochi", "Tokyo"},
+		WeatherDataMap: make(map[string]WeatherData),
+	})
+	if city := store.StartCity(); city != "Tucson" {
+		t.Errorf("without a default the first city starts, got %q", city)
+	}
+
+	moves := []struct {
+		cityName string
+		offset   int
+		moved    bool
+		want     []string
+	}{
+		{"Tokyo", -1, true, []string{"Tucson", "Tokyo", "Kochi"}},
+		{"Tucson", 1, true, []string{"Tokyo", "Tucson", "Kochi"}},
+		{"Tokyo", -1, false, []string{"Tokyo", "Tucson", "Kochi"}},
+		{"Kochi", 1, false, []string{"Tokyo", "Tucson", "Kochi"}},
+		{"Kochi", -5, true, []string{"Kochi", "Tokyo", "Tucson"}},
+		{"Kochi", 2, true, []string{"Tokyo", "Tucson", "Kochi"}},
+		{"Atlantis", 1, false, []string{"Tokyo", "Tucson", "Kochi"}},
+	}
+	for _, move := range moves {
+		if moved := store.MoveCity(move.cityName, move.offset); moved != move.moved {
+			t.Errorf("MoveCity(%s, %d) = %v, want %v", move.cityName, move.offset, moved, move.moved)
+		}
+		if names := store.CityNames(); !reflect.DeepEqual(names, move.want) {
+			t.Errorf("after MoveCity(%s, %d) got %v, want %v", move.cityName, move.offset, names, move.want)
+		}
+	}
+
+	if store.SetDefaultCity("Atlantis") {
+		t.Errorf("SetDefaultCity pinned an untracked city")
+	}
+	if !store.SetDefaultCity("Kochi") || store.StartCity() != "Kochi" {
+		t.Errorf("the pinned city %q does not start", store.StartCity())
+	}
+	if snapshot := store.Snapshot(); snapshot.DefaultCity != "Kochi" {
+		t.Errorf("the snapshot lost the default city: %+v", snapshot)
+	}
+	// removing the default city unpins it
+	store.RemoveCity("Kochi")
+	if city, start := store.DefaultCity(), store.StartCity(); city != "" || start != "Tokyo" {
+		t.Errorf("after removing the default city got default %q and start %q", city, start)
+	}
+}
@@ -1,35 +1,30 @@
 package main
 
-import "fmt"
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+)
 
 func main2() {
+	provider := newWeatherAPIProvider(os.Getenv(apiKeyEnv), newHTTPClient(5*time.Second, 15*time.Second))
 
 	currentState := CurrentState{
 		CityNames:      []string{},
 		WeatherDataMap: make(map[string]WeatherData),
 	}
 	//adding cities from cityNames.txt
-	if err := loadCityNamesFromFile(&currentState); err != nil {
+	if err := loadCityNamesFromFile(&currentState, cityNamesFileName); err != nil {
 		fmt.Println("Error loading city names into file:", err)
 		return
 	}
 
-	dataChannel := make(chan WeatherData, len(currentState.CityNames)) // Create a buffered channel
-
-	// Launch Goroutines to fetch and process data for each city
-	for _, cityName := range currentState.CityNames {
-		go func(cityName string) {
-			cityData := getCityData(cityName)
-			dataChannel <- cityData // Send the data to the channel
-		}(cityName)
-	}
-
-	// Receive the data from the channel for each city
-	for range currentState.CityNames {
-		cityData := <-dataChannel
-		// Add cityData to the WeatherDataMap with cityName as the key
-		currentState.WeatherDataMap[cityData.CityName] = cityData
+	weatherDataMap, fetchErrors := getAllCityData(context.Background(), provider, currentState.CityNames)
+	for cityName, err := range fetchErrors {
+		fmt.Println("Error:", cityName, err)
 	}
+	currentState.WeatherDataMap = weatherDataMap
 
 	// Iterate through all the values in the WeatherDataMap
 	for cityName, cityData := range currentState.WeatherDataMap {
@@ -0,0 +1,286 @@
+// This file contains the WeatherProvider implementation backed by the WeatherAPI
+// (https://www.weatherapi.com/). It uses packages like net/http and net/url to
+// request the forecast.json endpoint, which has the current conditions as well.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// defaultBaseURL is where the WeatherAPI endpoints are served.
+const defaultBaseURL = "http://api.weatherapi.com/v1"
+
+// maxForecastHours is how many hours of the hourly forecast are kept.
+const maxForecastHours = 48
+
+// defaultForecastDays and maxForecastDays are the days of forecast requested by
+// default and the most the WeatherAPI gives.
+const (
+	defaultForecastDays = 3
+	maxForecastDays     = 14
+)
+
+// WeatherAPIProvider fetches weather data from the WeatherAPI.
+type WeatherAPIProvider struct {
+	BaseURL string       // URL the endpoint names are appended to, e.g. defaultBaseURL.
+	APIKey  string       // Key sent with every request to the WeatherAPI.
+	Client  *http.Client // Client used for all requests, see newHTTPClient.
+	Retry   RetryPolicy  // How transient failures are retried, the zero value does not retry.
+	Days    int          // Days of forecast requested, 1 to maxForecastDays; 0 for defaultForecastDays.
+
+	flights *flightGroup // Coalesces concurrent requests for the same data, nil to not coalesce.
+}
+
+// newWeatherAPIProvider returns a WeatherAPIProvider for the real WeatherAPI that
+// authenticates with apiKey and sends its requests with client.
+func newWeatherAPIProvider(apiKey string, client *http.Client) WeatherAPIProvider {
+	return WeatherAPIProvider{BaseURL: defaultBaseURL, APIKey: apiKey, Client: client, flights: newFlightGroup()}
+}
+
+// newHTTPClient returns the client shared by all API calls. connectTimeout bounds
+// dialing and the TLS handshake, timeout bounds a whole request including the body.
+func newHTTPClient(connectTimeout, timeout time.Duration) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = (&net.Dialer{Timeout: connectTimeout, KeepAlive: 30 * time.Second}).DialContext
+	transport.TLSHandshakeTimeout = connectTimeout
+	return &http.Client{Transport: transport, Timeout: timeout}
+}
+
+// Weather retrieves the current conditions and the forecast for the next p.Days
+// days of a specified city, both from a single forecast.json request.
+// It takes the cityName as a parameter and returns a WeatherData struct. When the
+// cache answers instead of a call refused over a limit, the older data is
+// returned marked Stale, with the limit in RefreshErr.
+func (p WeatherAPIProvider) Weather(ctx context.Context, cityName string) (WeatherData, error) {
+	collectedData := WeatherData{CityName: cityName}
+
+	days := p.Days
+	if days <= 0 {
+		days = defaultForecastDays
+	}
+	responseBody, err := p.get(ctx, "forecast.json", cityName, fmt.Sprintf("&days=%d", days))
+	var fallback *fallbackError
+	if errors.As(err, &fallback) {
+		// older data from the cache, shown as stale with the reason it was not updated
+		responseBody, err = fallback.Body, nil
+		collectedData.Stale, collectedData.RefreshErr = true, fallback.Err
+	}
+	if err != nil {
+		return collectedData, err
+	}
+
+	// Parse the JSON response
+	var data forecastResponse
+	if err := decodeResponse(responseBody, &data); err != nil {
+		return collectedData, fmt.Errorf("%w: %v", ErrMalformedResponse, err)
+	}
+
+	//assigning values to struct
+	current := data.Current
+	collectedData.TempC0 = current.TempC
+	collectedData.TempF0 = current.TempF
+	collectedData.Humidity = current.Humidity
+	collectedData.WindMPH = current.WindMPH
+	collectedData.WindKPH = current.WindKPH
+	collectedData.PrecipInches = current.PrecipIn
+	collectedData.PrecipMm = current.PrecipMm
+	collectedData.Uv = current.Uv
+	collectedData.Condition = current.Condition.Text
+	collectedData.ConditionCode = current.Condition.Code
+	collectedData.IsDay = current.IsDay == 1
+	collectedData.WindDir = current.WindDir
+	collectedData.Icon0 = getImageString(current.Condition.Icon)
+	collectedData.Pressure = current.PressureMb
+	collectedData.UpdatedAt = time.Unix(current.LastUpdatedEpoch, 0)
+
+	//collect the forecast, a response without it still has the current conditions
+	if err := data.Forecast.validate(); err != nil {
+		collectedData.ForecastErr = fmt.Errorf("%w: %v", ErrMalformedResponse, err)
+		return collectedData, nil
+	}
+	for i, forecastDay := range data.Forecast.ForecastDay {
+		if i == days {
+			break
+		}
+		collectedData.Forecast = append(collectedData.Forecast, dailyForecast(forecastDay))
+		//the hours from the one the current conditions are for on
+		for _, hour := range forecastDay.Hour {
+			if hour.TimeEpoch+3600 > current.LastUpdatedEpoch && len(collectedData.Hourly) < maxForecastHours {
+				collectedData.Hourly = append(collectedData.Hourly, hourlyForecast(hour))
+			}
+		}
+	}
+
+	return collectedData, nil
+}
+
+// dailyForecast converts a forecast day of the WeatherAPI into a DailyForecast.
+func dailyForecast(forecastDay apiForecastDay) DailyForecast {
+	day := forecastDay.Day
+	date, err := time.Parse("2006-01-02", forecastDay.Date)
+	if err != nil {
+		date = time.Unix(forecastDay.DateEpoch, 0).UTC()
+	}
+	return DailyForecast{
+		Date:              date,
+		MaxTempC:          day.MaxTempC,
+		MaxTempF:          day.MaxTempF,
+		MinTempC:          day.MinTempC,
+		MinTempF:          day.MinTempF,
+		AvgTempC:          day.AvgTempC,
+		AvgTempF:          day.AvgTempF,
+		Condition:         day.Condition.Text,
+		ConditionCode:     day.Condition.Code,
+		Icon:              getImageString(day.Condition.Icon),
+		ChanceOfRain:      day.DailyChanceOfRain,
+		ChanceOfSnow:      day.DailyChanceOfSnow,
+		TotalPrecipMm:     day.TotalPrecipMm,
+		TotalPrecipInches: day.TotalPrecipIn,
+		MaxWindKPH:        day.MaxWindKPH,
+		MaxWindMPH:        day.MaxWindMPH,
+		Uv:                day.Uv,
+	}
+}
+
+// hourlyForecast converts an hour of the WeatherAPI forecast into an HourlyForecast.
+func hourlyForecast(hour apiHour) HourlyForecast {
+	return HourlyForecast{
+		Time:          hourTime(hour),
+		TempC:         hour.TempC,
+		TempF:         hour.TempF,
+		FeelsLikeC:    hour.FeelsLikeC,
+		FeelsLikeF:    hour.FeelsLikeF,
+		ChanceOfRain:  hour.ChanceOfRain,
+		ChanceOfSnow:  hour.ChanceOfSnow,
+		WindKPH:       hour.WindKPH,
+		WindMPH:       hour.WindMPH,
+		WindDir:       hour.WindDir,
+		Condition:     hour.Condition.Text,
+		ConditionCode: hour.Condition.Code,
+		Icon:          getImageString(hour.Condition.Icon),
+		IsDay:         hour.IsDay == 1,
+	}
+}
+
+// hourTime returns the start of hour in the city's time zone. The offset is worked
+// out from the local "time" and the "time_epoch" of the hour, so that no time zone
+// database is needed.
+func hourTime(hour apiHour) time.Time {
+	t := time.Unix(hour.TimeEpoch, 0)
+	local, err := time.Parse("2006-01-02 15:04", hour.Time)
+	if err != nil {
+		return t
+	}
+	return t.In(time.FixedZone("", int(local.Unix()-hour.TimeEpoch)))
+}
+
+// get sends a GET request for cityName to an endpoint of the WeatherAPI, with
+// params appended to the query string, and returns the response body.
+// Transient failures are retried according to p.Retry. Failures are reported
+// with the errors in errors.go, or ctx.Err() when ctx is cancelled or its
+// deadline passes. Concurrent calls for the same endpoint and city (in any case
+// and spacing, see queryKey) share one request.
+func (p WeatherAPIProvider) get(ctx context.Context, endpoint, cityName, params string) ([]byte, error) {
+	// Define the API endpoint URL
+	apiUrl := strings.TrimSuffix(p.BaseURL, "/") + "/" + endpoint + "?key=" + p.APIKey + "&q=" + url.QueryEscape(cityName) + params
+
+	key := endpoint + "?q=" + queryKey(cityName) + params
+	if cacheOnly(ctx) {
+		key += "&cache-only"
+	}
+	if revalidate(ctx) {
+		key += "&revalidate"
+	}
+	return p.flights.do(ctx, key, func(ctx context.Context) ([]byte, error) {
+		var responseBody []byte
+		err := p.Retry.retry(ctx, func() error {
+			var err error
+			responseBody, err = p.getOnce(ctx, apiUrl)
+			return err
+		})
+		return responseBody, err
+	})
+}
+
+// getOnce sends a single GET request to apiUrl and returns the response body.
+func (p WeatherAPIProvider) getOnce(ctx context.Context, apiUrl string) ([]byte, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, apiUrl, nil)
+	if err != nil {
+		return nil, fmt.Errorf("building request: %s", redact(err.Error(), p.APIKey))
+	}
+
+	// Send an HTTP GET request to the API
+	response, err := p.Client.Do(request)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		// refused by the cache or the client-side limits, the API was not called
+		for _, local := range []error{errCacheMiss, ErrRateLimited, ErrQuotaExceeded} {
+			if errors.Is(err, local) {
+				return nil, unwrapURLError(err)
+			}
+		}
+		// the error contains the request URL, which includes the key
+		return nil, fmt.Errorf("%w: %s", ErrNetwork, redact(err.Error(), p.APIKey))
+	}
+	defer response.Body.Close()
+
+	// Read the response body
+	responseBody, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, fmt.Errorf("%w: reading response body: %s", ErrNetwork, redact(err.Error(), p.APIKey))
+	}
+
+	// Check the response status code
+	if response.StatusCode != http.StatusOK {
+		apiErr := parseAPIError(response.StatusCode, responseBody)
+		apiErr.RetryAfter = parseRetryAfter(response.Header.Get("Retry-After"), time.Now())
+		return nil, apiErr
+	}
+	if limit := fallbackLimit(response); limit != nil {
+		return nil, &fallbackError{Body: responseBody, Err: limit}
+	}
+
+	return responseBody, nil
+}
+
+// fallbackError is returned by get when the WeatherAPI could not be called over
+// a limit and the cache answered with an older response instead, see
+// cachingTransport.
+type fallbackError struct {
+	Body []byte // The cached response body.
+	Err  error  // ErrRateLimited or ErrQuotaExceeded.
+}
+
+// Error implements the error interface.
+func (e *fallbackError) Error() string {
+	return "showing cached data: " + e.Err.Error()
+}
+
+// Unwrap returns the limit the request failed over.
+func (e *fallbackError) Unwrap() error {
+	return e.Err
+}
+
+// unwrapURLError returns the error a transport failed with, without the
+// *url.Error added by http.Client, whose message includes the key.
+func unwrapURLError(err error) error {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return urlErr.Err
+	}
+	return err
+}
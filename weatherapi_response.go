@@ -0,0 +1,207 @@
+// This file contains the Go types that the WeatherAPI forecast.json payload is
+// decoded into, see
+// https://www.weatherapi.com/docs/ for the meaning of each field.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// apiLocation is the "location" block returned by every WeatherAPI endpoint.
+type apiLocation struct {
+	Name           string  `json:"name"`
+	Region         string  `json:"region"`
+	Country        string  `json:"country"`
+	Lat            float64 `json:"lat"`
+	Lon            float64 `json:"lon"`
+	TzID           string  `json:"tz_id"`
+	LocaltimeEpoch int64   `json:"localtime_epoch"`
+	Localtime      string  `json:"localtime"`
+}
+
+// apiCondition describes the weather condition with text, an icon path and a condition code.
+type apiCondition struct {
+	Text string `json:"text"`
+	Icon string `json:"icon"`
+	Code int    `json:"code"`
+}
+
+// apiCurrent is the "current" block with the real time weather for a location.
+type apiCurrent struct {
+	LastUpdatedEpoch int64        `json:"last_updated_epoch"`
+	LastUpdated      string       `json:"last_updated"`
+	TempC            float64      `json:"temp_c"`
+	TempF            float64      `json:"temp_f"`
+	IsDay            int          `json:"is_day"`
+	Condition        apiCondition `json:"condition"`
+	WindMPH          float64      `json:"wind_mph"`
+	WindKPH          float64      `json:"wind_kph"`
+	WindDegree       float64      `json:"wind_degree"`
+	WindDir          string       `json:"wind_dir"`
+	PressureMb       float64      `json:"pressure_mb"`
+	PressureIn       float64      `json:"pressure_in"`
+	PrecipMm         float64      `json:"precip_mm"`
+	PrecipIn         float64      `json:"precip_in"`
+	Humidity         float64      `json:"humidity"`
+	Cloud            float64      `json:"cloud"`
+	FeelsLikeC       float64      `json:"feelslike_c"`
+	FeelsLikeF       float64      `json:"feelslike_f"`
+	WindChillC       float64      `json:"windchill_c"`
+	WindChillF       float64      `json:"windchill_f"`
+	HeatIndexC       float64      `json:"heatindex_c"`
+	HeatIndexF       float64      `json:"heatindex_f"`
+	DewPointC        float64      `json:"dewpoint_c"`
+	DewPointF        float64      `json:"dewpoint_f"`
+	VisKm            float64      `json:"vis_km"`
+	VisMiles         float64      `json:"vis_miles"`
+	Uv               float64      `json:"uv"`
+	GustMPH          float64      `json:"gust_mph"`
+	GustKPH          float64      `json:"gust_kph"`
+}
+
+// apiDay is the "day" block of a forecast day with the daily summary.
+type apiDay struct {
+	MaxTempC          float64      `json:"maxtemp_c"`
+	MaxTempF          float64      `json:"maxtemp_f"`
+	MinTempC          float64      `json:"mintemp_c"`
+	MinTempF          float64      `json:"mintemp_f"`
+	AvgTempC          float64      `json:"avgtemp_c"`
+	AvgTempF          float64      `json:"avgtemp_f"`
+	MaxWindMPH        float64      `json:"maxwind_mph"`
+	MaxWindKPH        float64      `json:"maxwind_kph"`
+	TotalPrecipMm     float64      `json:"totalprecip_mm"`
+	TotalPrecipIn     float64      `json:"totalprecip_in"`
+	TotalSnowCm       float64      `json:"totalsnow_cm"`
+	AvgVisKm          float64      `json:"avgvis_km"`
+	AvgVisMiles       float64      `json:"avgvis_miles"`
+	AvgHumidity       float64      `json:"avghumidity"`
+	DailyWillItRain   int          `json:"daily_will_it_rain"`
+	DailyChanceOfRain float64      `json:"daily_chance_of_rain"`
+	DailyWillItSnow   int          `json:"daily_will_it_snow"`
+	DailyChanceOfSnow float64      `json:"daily_chance_of_snow"`
+	Condition         apiCondition `json:"condition"`
+	Uv                float64      `json:"uv"`
+}
+
+// apiAstro is the "astro" block of a forecast day.
+type apiAstro struct {
+	Sunrise          string      `json:"sunrise"`
+	Sunset           string      `json:"sunset"`
+	Moonrise         string      `json:"moonrise"`
+	Moonset          string      `json:"moonset"`
+	MoonPhase        string      `json:"moon_phase"`
+	MoonIllumination json.Number `json:"moon_illumination"` // Sent as a number or a numeric string.
+	IsMoonUp         int         `json:"is_moon_up"`
+	IsSunUp          int         `json:"is_sun_up"`
+}
+
+// apiHour is one entry of the "hour" array of a forecast day.
+type apiHour struct {
+	TimeEpoch    int64        `json:"time_epoch"`
+	Time         string       `json:"time"`
+	TempC        float64      `json:"temp_c"`
+	TempF        float64      `json:"temp_f"`
+	IsDay        int          `json:"is_day"`
+	Condition    apiCondition `json:"condition"`
+	WindMPH      float64      `json:"wind_mph"`
+	WindKPH      float64      `json:"wind_kph"`
+	WindDegree   float64      `json:"wind_degree"`
+	WindDir      string       `json:"wind_dir"`
+	PressureMb   float64      `json:"pressure_mb"`
+	PressureIn   float64      `json:"pressure_in"`
+	PrecipMm     float64      `json:"precip_mm"`
+	PrecipIn     float64      `json:"precip_in"`
+	SnowCm       float64      `json:"snow_cm"`
+	Humidity     float64      `json:"humidity"`
+	Cloud        float64      `json:"cloud"`
+	FeelsLikeC   float64      `json:"feelslike_c"`
+	FeelsLikeF   float64      `json:"feelslike_f"`
+	WindChillC   float64      `json:"windchill_c"`
+	WindChillF   float64      `json:"windchill_f"`
+	HeatIndexC   float64      `json:"heatindex_c"`
+	HeatIndexF   float64      `json:"heatindex_f"`
+	DewPointC    float64      `json:"dewpoint_c"`
+	DewPointF    float64      `json:"dewpoint_f"`
+	WillItRain   int          `json:"will_it_rain"`
+	ChanceOfRain float64      `json:"chance_of_rain"`
+	WillItSnow   int          `json:"will_it_snow"`
+	ChanceOfSnow float64      `json:"chance_of_snow"`
+	VisKm        float64      `json:"vis_km"`
+	VisMiles     float64      `json:"vis_miles"`
+	GustMPH      float64      `json:"gust_mph"`
+	GustKPH      float64      `json:"gust_kph"`
+	Uv           float64      `json:"uv"`
+}
+
+// apiForecastDay is one entry of the "forecastday" array.
+type apiForecastDay struct {
+	Date      string    `json:"date"`
+	DateEpoch int64     `json:"date_epoch"`
+	Day       *apiDay   `json:"day"`
+	Astro     apiAstro  `json:"astro"`
+	Hour      []apiHour `json:"hour"`
+}
+
+// apiForecast is the "forecast" block of a forecast.json response.
+type apiForecast struct {
+	ForecastDay []apiForecastDay `json:"forecastday"`
+}
+
+// forecastResponse is the payload of the forecast.json endpoint. It also
+// contains the current conditions.
+type forecastResponse struct {
+	Location *apiLocation `json:"location"`
+	Current  *apiCurrent  `json:"current"`
+	Forecast *apiForecast `json:"forecast"`
+}
+
+// errIncompleteResponse is returned when a payload decodes but is missing a required block.
+var errIncompleteResponse = errors.New("incomplete response")
+
+// validate reports an error when c is missing the fields the application relies on.
+func (c *apiCurrent) validate() error {
+	if c == nil {
+		return fmt.Errorf("%w: no current block", errIncompleteResponse)
+	}
+	if c.LastUpdatedEpoch == 0 || c.Condition.Text == "" {
+		return fmt.Errorf("%w: current block without last_updated_epoch or condition", errIncompleteResponse)
+	}
+	return nil
+}
+
+// validate reports an error when f is missing the forecast days.
+func (f *apiForecast) validate() error {
+	if f == nil {
+		return fmt.Errorf("%w: no forecast block", errIncompleteResponse)
+	}
+	if len(f.ForecastDay) == 0 {
+		return fmt.Errorf("%w: no forecast days", errIncompleteResponse)
+	}
+	for i, day := range f.ForecastDay {
+		if day.Day == nil {
+			return fmt.Errorf("%w: forecast day %d without day block", errIncompleteResponse, i+1)
+		}
+	}
+	return nil
+}
+
+// validate reports an error when r is missing the location or current conditions.
+// The forecast is checked separately with r.Forecast.validate, so that the current
+// conditions can be used without it.
+func (r *forecastResponse) validate() error {
+	if r.Location == nil {
+		return fmt.Errorf("%w: no location block", errIncompleteResponse)
+	}
+	return r.Current.validate()
+}
+
+// decodeResponse decodes a WeatherAPI payload into v and checks that it is complete.
+func decodeResponse(body []byte, v interface{ validate() error }) error {
+	if err := json.Unmarshal(body, v); err != nil {
+		return err
+	}
+	return v.validate()
+}
@@ -0,0 +1,214 @@
+// test file that tests the code in the weatherapi.go file against the fake WeatherAPI
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestWeatherAPIProvider tests that the provider decodes the recorded responses
+// and maps the WeatherAPI failures to the errors in errors.go
+// tested features - WeatherAPIProvider, getCityData, RetryPolicy
+func TestWeatherAPIProvider(t *testing.T) {
+	fake := newFakeWeatherAPI(t)
+	provider := fake.provider()
+	ctx := context.Background()
+
+	data, err := getCityData(ctx, provider, "Tucson")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if data.CityName != "Tucson" || data.TempC0 != 24 || data.Condition != "Sunny" || data.Uv != 7 || data.Icon0 != "day/113.png" ||
+		data.ConditionCode != 1000 || !data.IsDay {
+		t.Errorf("current conditions decoded incorrectly: %+v", data)
+	}
+	if data.UpdatedAt != time.Unix(1696866300, 0) {
+		t.Errorf("UpdatedAt = %v", data.UpdatedAt)
+	}
+	if data.ForecastErr != nil || len(data.Forecast) != 3 {
+		t.Fatalf("forecast decoded incorrectly: %+v", data)
+	}
+	day := data.Forecast[0]
+	if day.Date != time.Date(2023, 10, 9, 0, 0, 0, 0, time.UTC) || day.AvgTempC == 0 || day.MaxTempC <= day.MinTempC || day.MaxTempF <= day.MinTempF ||
+		day.Condition == "" || day.ConditionCode == 0 || day.Icon == "" {
+		t.Errorf("forecast day decoded incorrectly: %+v", day)
+	}
+
+	// the hours start with the one the current conditions (08:45) are for, in Tucson time
+	if len(data.Hourly) != maxForecastHours {
+		t.Fatalf("got %d hours, want %d", len(data.Hourly), maxForecastHours)
+	}
+	hour := data.Hourly[0]
+	if hour.Time.Format("2006-01-02 15:04 -0700") != "2023-10-09 08:00 -0700" || !hour.IsDay || hour.Condition == "" {
+		t.Errorf("first hour decoded incorrectly: %+v", hour)
+	}
+	if last := data.Hourly[len(data.Hourly)-1]; last.Time.Sub(hour.Time) != (maxForecastHours-1)*time.Hour {
+		t.Errorf("hours not consecutive, last one at %v", last.Time)
+	}
+
+	// fewer days than the response has are cut off
+	short := provider
+	short.Days = 2
+	data, err = getCityData(ctx, short, "Tucson")
+	if err != nil || len(data.Forecast) != 2 {
+		t.Errorf("asked for 2 days, got %d, %v", len(data.Forecast), err)
+	}
+
+	// the current conditions and the forecast come from a single request
+	if count := fake.requestCount(""); count != 2 {
+		t.Errorf("%d requests for two fetches, want 2", count)
+	}
+
+	if _, err := getCityData(ctx, provider, "Atlantis"); !errors.Is(err, ErrCityNotFound) {
+		t.Errorf("expected ErrCityNotFound, got %v", err)
+	}
+
+	wrongKey := provider
+	wrongKey.APIKey = "wrong"
+	if _, err := getCityData(ctx, wrongKey, "Tucson"); !errors.Is(err, ErrInvalidAPIKey) {
+		t.Errorf("expected ErrInvalidAPIKey, got %v", err)
+	}
+
+	quota, _ := os.ReadFile(filepath.Join("testdata", "weatherapi", "error_2007.json"))
+	fake.queue(fakeResponse{status: http.StatusForbidden, body: string(quota)})
+	if _, err := getCityData(ctx, provider, "Tucson"); !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("expected ErrQuotaExceeded, got %v", err)
+	}
+
+	fake.queue(fakeResponse{status: http.StatusOK, body: `{"location":{"name":"Tucson"},"current":{"temp_c":`})
+	if _, err := getCityData(ctx, provider, "Tucson"); !errors.Is(err, ErrMalformedResponse) {
+		t.Errorf("expected ErrMalformedResponse, got %v", err)
+	}
+
+	// the current conditions are kept when only the forecast is missing
+	fake.queue(fakeResponse{status: http.StatusOK, body: `{"location":{"name":"Tucson"},"current":` +
+		`{"last_updated_epoch":1696866300,"temp_c":24.0,"condition":{"text":"Sunny"}},"forecast":{"forecastday":[]}}`})
+	data, err = getCityData(ctx, provider, "Tucson")
+	if err != nil || data.TempC0 != 24 || !errors.Is(data.ForecastErr, ErrMalformedResponse) {
+		t.Errorf("expected current conditions with a forecast error, got %+v, %v", data, err)
+	}
+}
+
+// TestWeatherAPIProviderRetry tests that transient failures are retried and
+// that the key never shows up in errors
+// tested features - WeatherAPIProvider, RetryPolicy, redact
+func TestWeatherAPIProviderRetry(t *testing.T) {
+	fake := newFakeWeatherAPI(t)
+	provider := fake.provider()
+	provider.Retry = RetryPolicy{Attempts: 3, BaseDelay: time.Millisecond, MaxDelay: 10 * time.Millisecond}
+
+	fake.queue(fakeResponse{status: http.StatusServiceUnavailable, body: "unavailable"},
+		fakeResponse{status: http.StatusTooManyRequests, header: http.Header{"Retry-After": {"0"}}, body: "slow down"})
+	data, err := provider.Weather(context.Background(), "Kochi")
+	if err != nil || data.Condition == "" {
+		t.Fatalf("expected success after retrying, got %+v, %v", data, err)
+	}
+	if got := fake.requestCount("forecast.json"); got != 3 {
+		t.Errorf("%d requests, want 3", got)
+	}
+
+	// a server that is gone is a network failure, reported without the key
+	provider.APIKey = "supersecretkey"
+	fake.Close()
+	_, err = provider.Weather(context.Background(), "Kochi")
+	if !errors.Is(err, ErrNetwork) {
+		t.Errorf("expected ErrNetwork, got %v", err)
+	}
+	if err != nil && strings.Contains(err.Error(), "supersecretkey") {
+		t.Errorf("key not redacted from error: %v", err)
+	}
+}
+
+// TestWeatherAPIProviderCoalescing tests that concurrent fetches of the same city
+// share one request, and that a caller giving up does not fail the
+// others
+// tested features - WeatherAPIProvider, flightGroup
+func TestWeatherAPIProviderCoalescing(t *testing.T) {
+	fake := newFakeWeatherAPI(t)
+	fake.setDelay(100 * time.Millisecond)
+	provider := fake.provider()
+
+	// one caller gives up right away, the others wait for the shared request
+	impatient, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	cityNames := []string{"Tucson", "tucson", " Tucson ", "Tucson", "TUCSON"}
+	results := make(chan cityResult, len(cityNames))
+	for i, cityName := range cityNames {
+		ctx := context.Background()
+		if i == 0 {
+			ctx = impatient
+		}
+		go func(ctx context.Context, cityName string) {
+			data, err := getCityData(ctx, provider, cityName)
+			results <- cityResult{Data: data, Err: err}
+		}(ctx, cityName)
+	}
+
+	failed := 0
+	for range cityNames {
+		result := <-results
+		if result.Err != nil {
+			if !errors.Is(result.Err, context.DeadlineExceeded) {
+				t.Errorf("unexpected error: %v", result.Err)
+			}
+			failed++
+			continue
+		}
+		if result.Data.TempC0 != 24 || result.Data.ForecastErr != nil {
+			t.Errorf("got %+v", result.Data)
+		}
+	}
+	if failed != 1 {
+		t.Errorf("%d callers failed, want only the impatient one", failed)
+	}
+	if count := fake.requestCount(""); count != 1 {
+		t.Errorf("got %d requests, want 1", count)
+	}
+
+	// once the request is done the next fetch sends a new one
+	fake.setDelay(0)
+	if _, err := getCityData(context.Background(), provider, "Tucson"); err != nil {
+		t.Fatal(err)
+	}
+	if count := fake.requestCount(""); count != 2 {
+		t.Errorf("got %d requests in total, want 2", count)
+	}
+
+	// coordinates that differ only in their sign are different places
+	fake.setDelay(50 * time.Millisecond)
+	var wg sync.WaitGroup
+	for _, query := range []string{"-33.87,151.21", "33.87,151.21"} {
+		wg.Add(1)
+		go func(query string) {
+			defer wg.Done()
+			getCityData(context.Background(), provider, query)
+		}(query)
+	}
+	wg.Wait()
+	if count := fake.requestCount(""); count != 4 {
+		t.Errorf("got %d requests in total, want 4, one per place", count)
+	}
+}
+
+// BenchmarkGetCityData fetches a city from the fake WeatherAPI and reports the
+// requests sent per fetch, which is 1 since both the current conditions and the
+// forecast come from forecast.json
+func BenchmarkGetCityData(b *testing.B) {
+	fake := newFakeWeatherAPI(b)
+	provider := fake.provider()
+	ctx := context.Background()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := getCityData(ctx, provider, "Tucson"); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.ReportMetric(float64(fake.requestCount(""))/float64(b.N), "requests/op")
+}